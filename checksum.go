@@ -0,0 +1,127 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unsafe"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// RowChecksum returns the CRC-32C of a row. Only the values and NULLs are
+// hashed, not how they are quoted or escaped, so a row has the same checksum
+// in any format. Each field is hashed as a 0 byte if it's NULL, else as a 1
+// byte, the uvarint length of the value and the value.
+func RowChecksum(row []Field) uint32 {
+	var crc uint32
+	var prefix [1 + binary.MaxVarintLen64]byte
+	for _, field := range row {
+		if field.IsNull {
+			prefix[0] = 0
+			crc = crc32.Update(crc, castagnoliTable, prefix[:1])
+			continue
+		}
+		prefix[0] = 1
+		n := binary.PutUvarint(prefix[1:], uint64(len(field.Val)))
+		crc = crc32.Update(crc, castagnoliTable, prefix[:1+n])
+		crc = crc32.Update(crc, castagnoliTable, unsafe.Slice(unsafe.StringData(field.Val), len(field.Val)))
+	}
+	return crc
+}
+
+// Digest is a fingerprint of a set of rows which doesn't depend on their
+// order, so that the rows of a file can be compared to the rows read back from
+// a database. The zero value is the digest of no row.
+type Digest struct {
+	Rows int64
+	// Sum is the sum and Xor the xor of the RowChecksum of the rows.
+	Sum uint64
+	Xor uint32
+}
+
+// Add adds a row to the digest.
+func (d *Digest) Add(row []Field) {
+	d.AddChecksum(RowChecksum(row))
+}
+
+// AddChecksum adds a row given its RowChecksum.
+func (d *Digest) AddChecksum(checksum uint32) {
+	d.Rows++
+	d.Sum += uint64(checksum)
+	d.Xor ^= checksum
+}
+
+// Merge adds the rows of other, for example of another shard of the file.
+func (d *Digest) Merge(other Digest) {
+	d.Rows += other.Rows
+	d.Sum += other.Sum
+	d.Xor ^= other.Xor
+}
+
+func (d Digest) String() string {
+	return fmt.Sprintf("%d:%016x:%08x", d.Rows, d.Sum, d.Xor)
+}
+
+// DigestRows reads all the rows of reader and returns their digest.
+func DigestRows(reader RowReader) (Digest, error) {
+	var d Digest
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return d, nil
+		}
+		if err != nil {
+			return d, err
+		}
+		d.Add(row)
+	}
+}
+
+// EnableDigest makes Read add the rows it returns to a Digest, see Digest.
+func (parser *CSVParser) EnableDigest() {
+	if parser.digest == nil {
+		parser.digest = &Digest{}
+	}
+}
+
+// Digest returns the digest of the rows returned by Read since EnableDigest.
+func (parser *CSVParser) Digest() Digest {
+	if parser.digest == nil {
+		return Digest{}
+	}
+	return *parser.digest
+}
+
+// EnableDigest makes Write add the rows to a Digest, the header is not added.
+// The rows are added as they are written, after SetEscapeFormulas, so that the
+// digest is the one of the rows a CSVParser reads back.
+func (w *CSVWriter) EnableDigest() {
+	if w.digest == nil {
+		w.digest = &Digest{}
+	}
+}
+
+// Digest returns the digest of the rows written since EnableDigest.
+func (w *CSVWriter) Digest() Digest {
+	if w.digest == nil {
+		return Digest{}
+	}
+	return *w.digest
+}
@@ -0,0 +1,114 @@
+package mydump_test
+
+import (
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowChecksum(t *testing.T) {
+	rows := [][]mydump.Field{
+		{newStringField("", false)},
+		{newStringField("", true)},
+		{newStringField(`\N`, false)},
+		{newStringField("ab", false), newStringField("", false)},
+		{newStringField("a", false), newStringField("b", false)},
+		{},
+	}
+	seen := make(map[uint32]int)
+	for i, row := range rows {
+		sum := mydump.RowChecksum(row)
+		_, ok := seen[sum]
+		require.False(t, ok, i)
+		seen[sum] = i
+	}
+	// only the values count, not how they were written.
+	quoted := mydump.Field{Val: "a", Quoted: true, Raw: `"a"`}
+	require.Equal(t, mydump.RowChecksum(rows[4][:1]), mydump.RowChecksum([]mydump.Field{quoted}))
+}
+
+func TestDigest(t *testing.T) {
+	mysqlCfg := mydump.NewMySQLConfig()
+	input := "1\t\\N\ta\\tb\n2\tx\\ny\t\n3\t\"q\"\t\\\\\n"
+	parser, err := mydump.NewCSVParser(mysqlCfg, NewStringReader(input), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	parser.EnableDigest()
+
+	rfcCfg := mydump.NewRFC4180Config()
+	rfcCfg.Null = []string{"NULL"}
+	var out strings.Builder
+	writer := mydump.NewCSVWriter(rfcCfg, &out)
+	writer.EnableDigest()
+	require.NoError(t, writer.WriteHeader([]string{"id", "a", "b"}))
+	var rows [][]mydump.Field
+	for {
+		row, meta, err := parser.ReadWithMeta()
+		if err != nil {
+			break
+		}
+		require.True(t, meta.HasChecksum)
+		require.Equal(t, mydump.RowChecksum(row), meta.Checksum)
+		rows = append(rows, row)
+	}
+	// the rows are written in another order and another format.
+	for _, i := range []int{2, 0, 1} {
+		require.NoError(t, writer.Write(rows[i]))
+	}
+	require.NoError(t, writer.Flush())
+	digest := parser.Digest()
+	require.Equal(t, int64(3), digest.Rows)
+	require.Equal(t, digest, writer.Digest())
+
+	reader, err := mydump.NewCSVParser(rfcCfg, NewStringReader(out.String()), int64(mydump.ReadBlockSize), true, false)
+	require.NoError(t, err)
+	readBack, err := mydump.DigestRows(reader)
+	require.NoError(t, err)
+	require.Equal(t, digest.String(), readBack.String())
+
+	var merged mydump.Digest
+	for _, row := range rows[:2] {
+		merged.Add(row)
+	}
+	var last mydump.Digest
+	last.Add(rows[2])
+	merged.Merge(last)
+	require.Equal(t, digest, merged)
+
+	rows[1][1].Val = "changed"
+	var changed mydump.Digest
+	for _, row := range rows {
+		changed.Add(row)
+	}
+	require.NotEqual(t, digest, changed)
+
+	// without EnableDigest, there is no checksum rather than a zero one.
+	parser, err = mydump.NewCSVParser(mysqlCfg, NewStringReader(input), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	_, meta, err := parser.ReadWithMeta()
+	require.NoError(t, err)
+	require.False(t, meta.HasChecksum)
+}
+
+func TestDigestEscapedFormulas(t *testing.T) {
+	cfg := mydump.NewExcelConfig()
+	var out strings.Builder
+	writer := mydump.NewExcelWriter(&out)
+	writer.EnableDigest()
+	row := []mydump.Field{newStringField("=1+1", false), newStringField("-1", false)}
+	require.NoError(t, writer.Write(row))
+	require.NoError(t, writer.Flush())
+	require.Equal(t, "'=1+1,-1\r\n", out.String())
+	require.Equal(t, "=1+1", row[0].Val)
+
+	// the digest is the one of what is written, and read back.
+	reader, err := mydump.NewCSVParser(cfg, NewStringReader(out.String()), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	readBack, err := mydump.DigestRows(reader)
+	require.NoError(t, err)
+	require.Equal(t, readBack, writer.Digest())
+	var original mydump.Digest
+	original.Add(row)
+	require.NotEqual(t, original, writer.Digest())
+}
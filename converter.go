@@ -0,0 +1,399 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale holds the separators used to write numbers.
+type Locale struct {
+	DecimalSeparator string
+	// ThousandsSeparator is optional in the values, but must separate groups
+	// of 3 digits when present. It's empty if numbers are never grouped.
+	ThousandsSeparator string
+}
+
+var (
+	LocaleC  = Locale{DecimalSeparator: "."}
+	LocaleUS = Locale{DecimalSeparator: ".", ThousandsSeparator: ","}
+	LocaleDE = Locale{DecimalSeparator: ",", ThousandsSeparator: "."}
+	// LocaleFR groups digits by the narrow no-break space, like CLDR.
+	LocaleFR = Locale{DecimalSeparator: ",", ThousandsSeparator: "\u202f"}
+	LocaleCH = Locale{DecimalSeparator: ".", ThousandsSeparator: "'"}
+)
+
+// ColumnType is the type a column is converted to.
+type ColumnType uint8
+
+const (
+	// TypeString keeps the value as a string.
+	TypeString ColumnType = iota
+	// TypeInt converts to int64.
+	TypeInt
+	// TypeDecimal converts to an exact *big.Rat.
+	TypeDecimal
+	// TypeFloat converts to float64.
+	TypeFloat
+	// TypeBool converts to bool.
+	TypeBool
+	// TypeDate converts to a time.Time at midnight.
+	TypeDate
+	// TypeTimestamp converts to a time.Time.
+	TypeTimestamp
+)
+
+var (
+	defaultTrueValues      = []string{"1", "true", "t", "yes", "y"}
+	defaultFalseValues     = []string{"0", "false", "f", "no", "n"}
+	defaultDateLayouts     = []string{time.DateOnly}
+	defaultTimestampLayout = []string{time.DateTime, time.RFC3339Nano}
+)
+
+// ColumnConverter converts the values of a column.
+type ColumnConverter struct {
+	// Column is the name of the column, as returned by Columns(). If it's
+	// empty, the column is the one at Index.
+	Column string
+	Index  int
+
+	Type ColumnType
+	// Locale overrides the locale of the Converter for numbers.
+	Locale *Locale
+	// TrueValues and FalseValues are the booleans, compared in any letter
+	// case. They default to 1, true, t, yes, y and 0, false, f, no, n.
+	TrueValues  []string
+	FalseValues []string
+	// Layouts are the time.Parse layouts of dates and timestamps, tried in
+	// order. Dates default to 2006-01-02, and timestamps to
+	// 2006-01-02 15:04:05 and RFC 3339.
+	Layouts []string
+	// Location is the time zone of the dates and timestamps without one, it
+	// defaults to UTC.
+	Location *time.Location
+	// EmptyIsNull converts empty values to nil instead of failing.
+	EmptyIsNull bool
+}
+
+// ConversionError is a value which couldn't be converted.
+type ConversionError struct {
+	// Row is the 1-based number of the row, the RowID of RowMeta if the
+	// reader has ReadWithMeta, or else counted by the Converter.
+	Row int64
+	// Line is the 1-based line where the row starts, or 0 if it's unknown.
+	Line   int64
+	Column string
+	Index  int
+	Value  string
+	Err    error
+}
+
+func (e *ConversionError) Error() string {
+	column := e.Column
+	if column == "" {
+		column = strconv.Itoa(e.Index)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("row %d (line %d) column %s: cannot convert %q: %v", e.Row, e.Line, column, e.Value, e.Err)
+	}
+	return fmt.Sprintf("row %d column %s: cannot convert %q: %v", e.Row, column, e.Value, e.Err)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// ErrTooManyConversionErrors is returned by Converter.Read once more than
+// MaxErrors values failed.
+var ErrTooManyConversionErrors = errors.New("too many conversion errors")
+
+// Converter converts the rows of a RowReader to typed values. NULL fields are
+// converted to nil, and the columns without converter to strings.
+//
+// Failed values are converted to nil and collected in Errors, the rows are
+// still returned.
+type Converter struct {
+	locale  Locale
+	columns []ColumnConverter
+	// bound[i] is the converter of the field i, or nil.
+	bound   []*ColumnConverter
+	isBound bool
+
+	// MaxErrors stops Read after that many errors, 0 means no limit.
+	MaxErrors int
+	errors    []*ConversionError
+	rows      int64
+}
+
+// NewConverter creates a Converter. The columns are bound by name on the first
+// Read, once the header is parsed.
+func NewConverter(locale Locale, columns ...ColumnConverter) *Converter {
+	return &Converter{locale: locale, columns: columns}
+}
+
+// Bind binds the columns to the fields of the rows, given the column names of
+// the input. It's called by the first Read.
+func (c *Converter) Bind(names []string) error {
+	c.bound = c.bound[:0]
+	for i := range c.columns {
+		col := &c.columns[i]
+		index := col.Index
+		if col.Column != "" {
+			index = -1
+			for j, name := range names {
+				if strings.EqualFold(name, col.Column) {
+					index = j
+					break
+				}
+			}
+			if index < 0 {
+				return fmt.Errorf("column %s is not in the columns %v", col.Column, names)
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("invalid column index %d", index)
+		}
+		for len(c.bound) <= index {
+			c.bound = append(c.bound, nil)
+		}
+		if c.bound[index] != nil {
+			return fmt.Errorf("column %d has two converters", index)
+		}
+		c.bound[index] = col
+	}
+	c.isBound = true
+	return nil
+}
+
+// metaReader is implemented by the RowReaders which tell where their rows
+// come from.
+type metaReader interface {
+	ReadWithMeta() ([]Field, RowMeta, error)
+}
+
+var _ metaReader = (*CSVParser)(nil)
+
+// Read reads and converts the next row of reader. If reader has
+// ReadWithMeta, like CSVParser, the errors have its RowID and Line.
+func (c *Converter) Read(reader RowReader) ([]any, error) {
+	var (
+		row  []Field
+		meta RowMeta
+		err  error
+	)
+	if mr, ok := reader.(metaReader); ok {
+		row, meta, err = mr.ReadWithMeta()
+	} else {
+		row, err = reader.Read()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !c.isBound {
+		if err = c.Bind(reader.Columns()); err != nil {
+			return nil, err
+		}
+	}
+	c.rows++
+	if meta.RowID == 0 {
+		meta.RowID = c.rows
+	}
+	values := c.convertRow(row, meta.RowID, meta.Line)
+	if c.MaxErrors > 0 && len(c.errors) > c.MaxErrors {
+		return nil, ErrTooManyConversionErrors
+	}
+	return values, nil
+}
+
+// Convert converts a row, Bind must have been called. The errors have the
+// number of rows converted as Row.
+func (c *Converter) Convert(row []Field) []any {
+	c.rows++
+	return c.convertRow(row, c.rows, 0)
+}
+
+func (c *Converter) convertRow(row []Field, rowID, line int64) []any {
+	values := make([]any, len(row))
+	for i, field := range row {
+		if field.IsNull {
+			continue
+		}
+		if i >= len(c.bound) || c.bound[i] == nil {
+			values[i] = field.Val
+			continue
+		}
+		col := c.bound[i]
+		value, err := c.convert(col, field.Val)
+		if err != nil {
+			c.errors = append(c.errors, &ConversionError{
+				Row:    rowID,
+				Line:   line,
+				Column: col.Column,
+				Index:  i,
+				Value:  field.Val,
+				Err:    err,
+			})
+			continue
+		}
+		values[i] = value
+	}
+	return values
+}
+
+// Errors returns the conversion errors so far.
+func (c *Converter) Errors() []*ConversionError {
+	return c.errors
+}
+
+func (c *Converter) convert(col *ColumnConverter, val string) (any, error) {
+	if col.Type == TypeString {
+		return val, nil
+	}
+	val = strings.TrimSpace(val)
+	if val == "" && col.EmptyIsNull {
+		return nil, nil
+	}
+	locale := &c.locale
+	if col.Locale != nil {
+		locale = col.Locale
+	}
+	switch col.Type {
+	case TypeInt:
+		num, err := normalizeNumber(val, locale, false)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(num, 10, 64)
+	case TypeDecimal:
+		num, err := normalizeNumber(val, locale, true)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := new(big.Rat).SetString(num)
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal")
+		}
+		return r, nil
+	case TypeFloat:
+		mantissa, exponent := val, ""
+		if i := strings.IndexAny(val, "eE"); i >= 0 {
+			mantissa, exponent = val[:i], val[i:]
+		}
+		num, err := normalizeNumber(mantissa, locale, true)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseFloat(num+exponent, 64)
+	case TypeBool:
+		return parseBool(col, val)
+	case TypeDate, TypeTimestamp:
+		return parseTime(col, val)
+	default:
+		return nil, fmt.Errorf("unknown column type %d", col.Type)
+	}
+}
+
+// normalizeNumber removes the thousands separators of s and replaces its
+// decimal separator by '.', so that it can be parsed by strconv or big.
+func normalizeNumber(s string, locale *Locale, fraction bool) (string, error) {
+	sign := ""
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+	intPart, fracPart, hasFrac := s, "", false
+	if sep := locale.DecimalSeparator; sep != "" {
+		intPart, fracPart, hasFrac = strings.Cut(s, sep)
+	}
+	if hasFrac && !fraction {
+		return "", errors.New("unexpected decimal separator")
+	}
+	if sep := locale.ThousandsSeparator; sep != "" && strings.Contains(intPart, sep) {
+		groups := strings.Split(intPart, sep)
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return "", errors.New("misplaced thousands separator")
+		}
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return "", errors.New("misplaced thousands separator")
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+	if !isDigits(intPart) || (hasFrac && !isDigits(fracPart)) || intPart+fracPart == "" {
+		return "", errors.New("invalid number")
+	}
+	if hasFrac {
+		return sign + intPart + "." + fracPart, nil
+	}
+	return sign + intPart, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseBool(col *ColumnConverter, val string) (bool, error) {
+	trueValues, falseValues := col.TrueValues, col.FalseValues
+	if trueValues == nil && falseValues == nil {
+		trueValues, falseValues = defaultTrueValues, defaultFalseValues
+	}
+	for _, v := range trueValues {
+		if strings.EqualFold(v, val) {
+			return true, nil
+		}
+	}
+	for _, v := range falseValues {
+		if strings.EqualFold(v, val) {
+			return false, nil
+		}
+	}
+	return false, errors.New("invalid boolean")
+}
+
+func parseTime(col *ColumnConverter, val string) (time.Time, error) {
+	layouts := col.Layouts
+	if layouts == nil {
+		layouts = defaultTimestampLayout
+		if col.Type == TypeDate {
+			layouts = defaultDateLayouts
+		}
+	}
+	loc := col.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, val, loc); err == nil {
+			if col.Type == TypeDate {
+				y, m, d := t.Date()
+				t = time.Date(y, m, d, 0, 0, 0, 0, loc)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
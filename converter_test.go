@@ -0,0 +1,140 @@
+package mydump_test
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ";",
+		FieldEnclosedBy:   `"`,
+		Null:              []string{"NULL"},
+		HeaderSchemaMatch: true,
+	}
+	input := "id;amount;ratio;paid;day;at;note\n" +
+		"1.234;1.234,56;2,5e3;ja;31/12/2023;2023-12-31 23:59:00;x\n" +
+		"7;-0,10;NULL;nein;01/02/2024;2024-02-01 08:00:00;y\n" +
+		"1,5;12.34;abc;maybe;2024-13-01;;z\n"
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), int64(mydump.ReadBlockSize), true, false)
+	require.NoError(t, err)
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	converter := mydump.NewConverter(mydump.LocaleDE,
+		mydump.ColumnConverter{Column: "id", Type: mydump.TypeInt},
+		mydump.ColumnConverter{Column: "amount", Type: mydump.TypeDecimal},
+		mydump.ColumnConverter{Column: "ratio", Type: mydump.TypeFloat},
+		mydump.ColumnConverter{Column: "paid", Type: mydump.TypeBool, TrueValues: []string{"ja"}, FalseValues: []string{"nein"}},
+		mydump.ColumnConverter{Column: "day", Type: mydump.TypeDate, Layouts: []string{"02/01/2006"}},
+		mydump.ColumnConverter{Index: 5, Type: mydump.TypeTimestamp, Location: berlin, EmptyIsNull: true},
+	)
+
+	row, err := converter.Read(parser)
+	require.NoError(t, err)
+	require.Equal(t, int64(1234), row[0])
+	require.Equal(t, 0, row[1].(*big.Rat).Cmp(big.NewRat(123456, 100)))
+	require.Equal(t, 2500.0, row[2])
+	require.Equal(t, true, row[3])
+	require.Equal(t, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), row[4])
+	require.Equal(t, time.Date(2023, 12, 31, 23, 59, 0, 0, berlin), row[5])
+	require.Equal(t, "x", row[6])
+
+	row, err = converter.Read(parser)
+	require.NoError(t, err)
+	require.Equal(t, 0, row[1].(*big.Rat).Cmp(big.NewRat(-1, 10)))
+	require.Nil(t, row[2])
+	require.Equal(t, false, row[3])
+	require.Empty(t, converter.Errors())
+
+	// failed values are nil and collected with their row and column.
+	row, err = converter.Read(parser)
+	require.NoError(t, err)
+	require.Equal(t, []any{nil, nil, nil, nil, nil, nil, "z"}, row)
+	errs := converter.Errors()
+	require.Len(t, errs, 5)
+	require.Equal(t, int64(3), errs[0].Row)
+	require.Equal(t, "id", errs[0].Column)
+	require.Equal(t, "1,5", errs[0].Value)
+	require.Equal(t, int64(4), errs[0].Line)
+	require.Equal(t, "row 3 (line 4) column amount: cannot convert \"12.34\": misplaced thousands separator", errs[1].Error())
+	require.Equal(t, "day", errs[4].Column)
+	require.EqualError(t, errors.Unwrap(errs[2]), "invalid number")
+
+	_, err = converter.Read(parser)
+	require.Equal(t, io.EOF, err)
+}
+
+func TestConverterErrorRows(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`, HeaderSchemaMatch: true}
+	input := "id,note\n1,\"a\nb\"\nx,c\n3,d\nx,e\ny,f\n"
+	parser, err := mydump.NewCSVParserFromBytes(&cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	everyOther, err := mydump.EveryNth(2)
+	require.NoError(t, err)
+	parser.SetFilter(everyOther)
+
+	// the rows are numbered like the input, not like the rows converted.
+	converter := mydump.NewConverter(mydump.LocaleUS, mydump.ColumnConverter{Column: "id", Type: mydump.TypeInt})
+	for {
+		_, err = converter.Read(parser)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	errs := converter.Errors()
+	require.Len(t, errs, 1)
+	require.Equal(t, int64(5), errs[0].Row)
+	require.Equal(t, int64(7), errs[0].Line)
+
+	// without ReadWithMeta, the Converter counts the rows.
+	converter = mydump.NewConverter(mydump.LocaleUS, mydump.ColumnConverter{Index: 0, Type: mydump.TypeInt})
+	require.NoError(t, converter.Bind(nil))
+	converter.Convert([]mydump.Field{newStringField("1", false)})
+	converter.Convert([]mydump.Field{newStringField("x", false)})
+	require.Equal(t, "row 2 column 0: cannot convert \"x\": invalid number", converter.Errors()[0].Error())
+}
+
+func TestConverterLocales(t *testing.T) {
+	cases := []struct {
+		locale mydump.Locale
+		val    string
+		ok     bool
+	}{
+		{mydump.LocaleUS, "1,234.5", true},
+		{mydump.LocaleUS, "1,23.5", false},
+		{mydump.LocaleFR, "1\u202f234,5", true},
+		{mydump.LocaleCH, "1'234.5", true},
+		{mydump.LocaleC, "1234.5", true},
+		{mydump.LocaleC, "1,234.5", false},
+		{mydump.LocaleDE, "+1.234,5", true},
+		{mydump.LocaleDE, "1,2,3", false},
+	}
+	for _, tc := range cases {
+		converter := mydump.NewConverter(tc.locale, mydump.ColumnConverter{Type: mydump.TypeFloat})
+		require.NoError(t, converter.Bind(nil))
+		row := converter.Convert([]mydump.Field{newStringField(tc.val, false)})
+		if tc.ok {
+			require.Empty(t, converter.Errors(), tc.val)
+			require.InDelta(t, 1234.5, row[0], 1e-9, tc.val)
+		} else {
+			require.Len(t, converter.Errors(), 1, tc.val)
+		}
+	}
+
+	converter := mydump.NewConverter(mydump.LocaleC, mydump.ColumnConverter{Type: mydump.TypeInt})
+	converter.MaxErrors = 1
+	parser, err := mydump.NewCSVParser(&mydump.CSVConfig{FieldTerminatedBy: ","}, NewStringReader("a\nb\n"), 4, false, false)
+	require.NoError(t, err)
+	_, err = converter.Read(parser)
+	require.NoError(t, err)
+	_, err = converter.Read(parser)
+	require.Equal(t, mydump.ErrTooManyConversionErrors, err)
+}
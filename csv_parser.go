@@ -16,12 +16,16 @@ package mydump
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"regexp"
+	"math/bits"
 	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
+	"unsafe"
 
 	"github.com/spkg/bom"
 )
@@ -40,8 +44,54 @@ var (
 type Field struct {
 	Val    string
 	IsNull bool
+	// Quoted tells whether the field was quoted in the input, it's only set
+	// with CSVConfig.KeepQuoting. Writers keep quoted fields quoted.
+	Quoted bool
+	// Raw is the content of the field before the escape sequences and NULL
+	// values are processed, doubled quotes are already collapsed. It's only
+	// set with CSVConfig.KeepRaw.
+	Raw string
 }
 
+// RowReader is implemented by the parsers of every supported format, so that
+// loaders can be written without caring about the input format.
+type RowReader interface {
+	// Read returns the next row, or io.EOF if there is no more row.
+	Read() ([]Field, error)
+	// Pos returns the position of the input that has been parsed.
+	Pos() int64
+	// Columns returns the column names, if the input provides them.
+	Columns() []string
+}
+
+// RowMeta tells where a row returned by ReadWithMeta comes from.
+type RowMeta struct {
+	// RowID is the 1-based number of the row, the header excluded.
+	RowID int64
+	// Start is the offset where the row starts, after LINES STARTING BY, and
+	// End the offset after its terminator.
+	Start int64
+	End   int64
+	// Line is the 1-based line where the row starts, counting the newlines
+	// inside quoted fields. Lines are only counted from the first call to
+	// ReadWithMeta, so it's 0 if rows were read by Read before, or after
+	// seeking to a row, since the lines before are unknown.
+	Line int64
+	// FieldOffsets is where each field starts, and FieldQuoted whether it's
+	// quoted.
+	FieldOffsets []int64
+	FieldQuoted  []bool
+	// Checksum is the RowChecksum of the row, HasChecksum tells whether it's
+	// set, which is only after EnableDigest.
+	Checksum    uint32
+	HasChecksum bool
+}
+
+var (
+	_ RowReader = (*CSVParser)(nil)
+	_ RowReader = (*FixedWidthParser)(nil)
+)
+
 type escapeFlavor uint8
 
 const (
@@ -50,6 +100,46 @@ const (
 	escapeFlavorMySQLWithNull
 )
 
+// TrimSpaceMode tells which side of the fields the spaces and tabs are trimmed.
+type TrimSpaceMode uint8
+
+const (
+	TrimSpaceNone     TrimSpaceMode = 0
+	TrimSpaceLeading  TrimSpaceMode = 1 << 0
+	TrimSpaceTrailing TrimSpaceMode = 1 << 1
+	TrimSpaceBoth                   = TrimSpaceLeading | TrimSpaceTrailing
+)
+
+// trimSpaceCutset is what TrimSpace trims.
+const trimSpaceCutset = " \t"
+
+// LineEnding tells which line endings terminate the rows when
+// LineTerminatedBy is empty.
+type LineEnding uint8
+
+const (
+	// LineEndingDefault treats each '\r' and '\n' as a terminator, so "\r\n"
+	// is a terminator followed by an empty line.
+	LineEndingDefault LineEnding = iota
+	// LineEndingLF only terminates the rows by "\n".
+	LineEndingLF
+	// LineEndingCRLF only terminates the rows by "\r\n".
+	LineEndingCRLF
+	// LineEndingCR only terminates the rows by "\r".
+	LineEndingCR
+	// LineEndingAny terminates the rows by "\n", "\r" or "\r\n", the latter
+	// being a single terminator.
+	LineEndingAny
+)
+
+// lineEndingTerminators are the terminators of the line endings which only
+// accept one.
+var lineEndingTerminators = map[LineEnding]string{
+	LineEndingLF:   "\n",
+	LineEndingCRLF: "\r\n",
+	LineEndingCR:   "\r",
+}
+
 type CSVConfig struct {
 	// they can only be used by LOAD DATA
 	// https://dev.mysql.com/doc/refman/8.0/en/load-data.html#load-data-field-line-handling
@@ -67,6 +157,12 @@ type CSVConfig struct {
 	NotNull           bool
 
 	AllowEmptyLine bool
+	// LineEnding chooses the line endings when LineTerminatedBy is empty, it
+	// can't be set together with LineTerminatedBy.
+	LineEnding LineEnding
+	// NormalizeQuotedNewLines turns the "\r\n" and "\r" inside quoted fields
+	// into "\n".
+	NormalizeQuotedNewLines bool
 	// For non-empty FieldEnclosedBy (for example quotes), null elements inside quotes are not considered as null except for
 	// `\N` (when escape-by is `\`). That is to say, `\N` is special for null because it always means null.
 	QuotedNullIsText bool
@@ -77,21 +173,162 @@ type CSVConfig struct {
 	// > The "BIG" boss      -> The "BIG" boss
 	// This means we will meet unescaped quote in an unquoted field
 	UnescapedQuote bool
+
+	// KeepQuoting sets Field.Quoted, and KeepRaw Field.Raw, in the rows read.
+	KeepQuoting bool
+	KeepRaw     bool
+
+	// TrimSpace trims the spaces and tabs around unquoted fields, before NULL
+	// values are detected. Spaces between a quote and the separator are then
+	// allowed on the trimmed side.
+	TrimSpace TrimSpaceMode
+	// TrimQuotedSpace applies TrimSpace to the content of quoted fields too.
+	TrimQuotedSpace bool
+
+	// SepHint makes the parser honor a `sep=X` first line, written by Excel, by
+	// using X as the separator and skipping the line.
+	SepHint bool
+	// UnwrapFormulaText reads a field written as ="..." as the quoted text. Excel
+	// uses it to keep the leading zeros of values like ="00123".
+	UnwrapFormulaText bool
+}
+
+// lineTerminator returns the terminator of the lines, which is empty when each
+// '\r' and '\n' is one.
+func (cfg *CSVConfig) lineTerminator() string {
+	if len(cfg.LineTerminatedBy) > 0 {
+		return cfg.LineTerminatedBy
+	}
+	return lineEndingTerminators[cfg.LineEnding]
+}
+
+// Validate checks that the tokens of the config can be told apart, and that
+// the Null values can be read. NewCSVParser calls it.
+func (cfg *CSVConfig) Validate() error {
+	if len(cfg.FieldTerminatedBy) == 0 {
+		return errors.New("FieldTerminatedBy cannot be empty")
+	}
+	if len(cfg.FieldEscapedBy) > 1 {
+		return fmt.Errorf("FieldEscapedBy '%s' must be a single byte", cfg.FieldEscapedBy)
+	}
+	if cfg.LineEnding > LineEndingAny {
+		return fmt.Errorf("unknown LineEnding %d", cfg.LineEnding)
+	}
+	if cfg.LineEnding != LineEndingDefault && len(cfg.LineTerminatedBy) > 0 {
+		return fmt.Errorf("LineTerminatedBy '%s' cannot be set together with LineEnding", cfg.LineTerminatedBy)
+	}
+	// an empty terminator ends the lines at each '\r' and '\n'.
+	if terminator := cfg.lineTerminator(); len(cfg.LineStartingBy) > 0 {
+		if len(terminator) > 0 && strings.Contains(cfg.LineStartingBy, terminator) {
+			return errors.New(fmt.Sprintf("STARTING BY '%s' cannot contain LINES TERMINATED BY '%s'", cfg.LineStartingBy, terminator))
+		}
+		if len(terminator) == 0 && strings.ContainsAny(cfg.LineStartingBy, "\r\n") {
+			return fmt.Errorf("STARTING BY %q cannot contain the line ending, '\\r' or '\\n'", cfg.LineStartingBy)
+		}
+	}
+
+	tokens := cfg.tokens()
+	for i, a := range tokens {
+		for _, b := range tokens[i+1:] {
+			if strings.HasPrefix(a.value, b.value) || strings.HasPrefix(b.value, a.value) {
+				return fmt.Errorf("%s %q and %s %q cannot be told apart", a.name, a.value, b.name, b.value)
+			}
+		}
+	}
+	if !cfg.NotNull {
+		for _, null := range cfg.Null {
+			if err := cfg.validateNull(null, tokens); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// configToken is a token of the CSV syntax, named after its config field.
+type configToken struct {
+	name  string
+	value string
+}
+
+// tokens returns the non-empty tokens of the config. An empty terminator means
+// both '\r' and '\n', which are named "the line ending" as the user didn't set
+// them.
+func (cfg *CSVConfig) tokens() []configToken {
+	tokens := []configToken{{"FieldTerminatedBy", cfg.FieldTerminatedBy}}
+	if len(cfg.FieldEnclosedBy) > 0 {
+		tokens = append(tokens, configToken{"FieldEnclosedBy", cfg.FieldEnclosedBy})
+	}
+	if len(cfg.FieldEscapedBy) > 0 {
+		tokens = append(tokens, configToken{"FieldEscapedBy", cfg.FieldEscapedBy})
+	}
+	switch {
+	case len(cfg.LineTerminatedBy) > 0:
+		tokens = append(tokens, configToken{"LineTerminatedBy", cfg.LineTerminatedBy})
+	case cfg.LineEnding != LineEndingDefault && cfg.LineEnding != LineEndingAny:
+		tokens = append(tokens, configToken{"LineEnding", cfg.lineTerminator()})
+	default:
+		tokens = append(tokens, configToken{"the line ending", "\r"}, configToken{"the line ending", "\n"})
+	}
+	return tokens
+}
+
+// validateNull checks that a field can be read as null. Without escape, an
+// unquoted field can't contain the tokens, nor the spaces TrimSpace removes.
+// A quoted field can contain anything, but it's only read as NULL without
+// QuotedNullIsText.
+func (cfg *CSVConfig) validateNull(null string, tokens []configToken) error {
+	if len(cfg.FieldEscapedBy) > 0 {
+		return nil
+	}
+	trimmed := false
+	if cfg.TrimSpace&TrimSpaceLeading != 0 {
+		trimmed = strings.TrimLeft(null, trimSpaceCutset) != null
+	}
+	if cfg.TrimSpace&TrimSpaceTrailing != 0 {
+		trimmed = trimmed || strings.TrimRight(null, trimSpaceCutset) != null
+	}
+	if len(cfg.FieldEnclosedBy) > 0 && !cfg.QuotedNullIsText && !(trimmed && cfg.TrimQuotedSpace) {
+		return nil
+	}
+	if trimmed {
+		return fmt.Errorf("Null value %q can never match, TrimSpace removes its spaces", null)
+	}
+	for _, token := range tokens {
+		if strings.Contains(null, token.value) {
+			return fmt.Errorf("Null value %q can never match, it contains %s %q", null, token.name, token.value)
+		}
+	}
+	return nil
+}
+
+// NewExcelConfig returns the config of the CSV files written and read by
+// Excel: comma separated, quoted by '"', with a possible `sep=` hint line and
+// ="..." text cells. Excel uses ';' in some locales, in which case set
+// FieldTerminatedBy or rely on the hint line. Both CRLF and the UTF-8 BOM
+// Excel writes are handled like for any config. Write for Excel with
+// NewExcelWriter, which also escapes formulas.
+func NewExcelConfig() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		SepHint:           true,
+		UnwrapFormulaText: true,
+	}
 }
 
 // CSVParser is basically a copy of encoding/csv, but special-cased for MySQL-like input.
 type CSVParser struct {
 	cfg *CSVConfig
 
-	comma          []byte
-	quote          []byte
-	newLine        []byte
-	startingBy     []byte
-	escapedBy      string
-	unescapeRegexp *regexp.Regexp
+	comma      []byte
+	quote      []byte
+	newLine    []byte
+	startingBy []byte
+	escapedBy  string
 
-	// These variables are used with IndexAnyByte to search a byte slice for the
-	// first index which some special character may appear.
+	// These variables are used with stopSet.index to search a byte slice for
+	// the first index which some special character may appear.
 	// quoteByteSet is used inside quoted fields (so the first characters of
 	// the closing delimiter and backslash are special).
 	// unquoteByteSet is used outside quoted fields (so the first characters
@@ -99,9 +336,9 @@ type CSVParser struct {
 	// special).
 	// newLineByteSet is used in strict-format CSV dividing (so the first
 	// characters of the terminator are special).
-	quoteByteSet   byteSet
-	unquoteByteSet byteSet
-	newLineByteSet byteSet
+	quoteByteSet   stopSet
+	unquoteByteSet stopSet
+	newLineByteSet stopSet
 
 	// recordBuffer holds the unescaped fields, one after another.
 	// The fields can be accessed by using the indexes in fieldIndexes.
@@ -113,14 +350,41 @@ type CSVParser struct {
 	// The width field ends at offset fieldIndexes[i] in recordBuffer.
 	fieldIndexes  []int
 	fieldIsQuoted []bool
+	// fieldOffsets is where each field starts in the input.
+	fieldOffsets []int64
+	// fieldSources is where the content of each field is in data when it's
+	// in data as is, else -1, so that it's sliced from input rather than
+	// copied. fieldSource is the one of the field being read. They are only
+	// tracked in memory.
+	fieldSources []int64
+	fieldSource  int64
+
+	// lines is the number of lines consumed, to tell the line of the rows.
+	// They are only counted once trackLines is set by ReadWithMeta, and
+	// linesUnknown is set if anything was consumed before, or after a seek.
+	// The lines end with lineSep, or with "\n", "\r\n" or '\r' if
+	// anyLineEnd. lastCR tells whether the last byte consumed is '\r'.
+	trackLines   bool
+	started      bool
+	lineSep      []byte
+	anyLineEnd   bool
+	lastCR       bool
+	lines        int64
+	linesUnknown bool
+	// recordStart and recordLine are where the last record starts.
+	recordStart int64
+	recordLine  int64
 
 	lastRecord []field
 
 	escFlavor escapeFlavor
 	// if set to true, csv parser will treat the first non-empty line as header line
 	shouldParseHeader bool
+	// if set to true, csv parser will check the first line for a `sep=` hint
+	shouldReadSepHint bool
 	// in LOAD DATA, empty line should be treated as a valid field
 	allowEmptyLine   bool
+	anyCRLF          bool
 	quotedNullIsText bool
 	unescapedQuote   bool
 
@@ -130,13 +394,25 @@ type CSVParser struct {
 	// used to read data from the reader, the data will be moved to other buffers.
 	blockBuf    []byte
 	isLastChunk bool
+	// inMemory is set when the whole input is in data, see NewCSVParserFromBytes.
+	// input is data as a string, aliasing it.
+	inMemory bool
+	data     []byte
+	input    string
 
 	// The list of column names of the last INSERT statement.
 	columns []string
 
 	lastRow []Field
-	rowID   int
-	length  int
+	// filter rejects rows before they are unescaped, see SetFilter.
+	filter RowFilter
+	// digest holds the rows read since EnableDigest, and checksum the
+	// RowChecksum of the last row.
+	digest   *Digest
+	checksum uint32
+	// rowID is the number of rows read.
+	rowID  int64
+	length int
 	// the reader position we have parsed, if the underlying reader is not
 	// a compressed file, it's the file position we have parsed too.
 	// this value may go backward when failed to read quoted field, but it's
@@ -149,6 +425,10 @@ type CSVParser struct {
 	appendBuf *bytes.Buffer
 
 	reuseRow bool
+
+	// progress reporting, see SetObserver and Stats.
+	observer ProgressObserver
+	progress progress
 }
 
 type field struct {
@@ -164,48 +444,37 @@ func NewCSVParser(
 	shouldParseHeader bool,
 	reuseRow bool,
 ) (*CSVParser, error) {
-	var err error
 	var separator, delimiter, terminator string
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	separator = cfg.FieldTerminatedBy
 	delimiter = cfg.FieldEnclosedBy
-	terminator = cfg.LineTerminatedBy
+	terminator = cfg.lineTerminator()
 
-	var quoteStopSet, newLineStopSet []byte
-	unquoteStopSet := []byte{separator[0]}
+	var quoteStopSet []byte
 	if len(delimiter) > 0 {
 		quoteStopSet = []byte{delimiter[0]}
-		unquoteStopSet = append(unquoteStopSet, delimiter[0])
 	}
-	if len(terminator) > 0 {
-		newLineStopSet = []byte{terminator[0]}
-	} else {
-		// The character set encoding of '\r' and '\n' is the same in UTF-8 and GBK.
-		newLineStopSet = []byte{'\r', '\n'}
-	}
-	unquoteStopSet = append(unquoteStopSet, newLineStopSet...)
-
-	if len(cfg.LineStartingBy) > 0 {
-		if strings.Contains(cfg.LineStartingBy, terminator) {
-			return nil, errors.New(fmt.Sprintf("STARTING BY '%s' cannot contain LINES TERMINATED BY '%s'", cfg.LineStartingBy, terminator))
-		}
+	newLineStopSet := newLineStopChars(terminator)
+	unquoteStopSet := unquoteStopChars(separator, delimiter, newLineStopSet, cfg.FieldEscapedBy)
+	// the lines of a file only ended by '\r' are counted by '\r', and both
+	// are counted when any line ending is accepted.
+	lineSep := []byte{'\n'}
+	if terminator == "\r" {
+		lineSep = []byte{'\r'}
 	}
 
 	escFlavor := escapeFlavorNone
-	var r *regexp.Regexp
 
 	if len(cfg.FieldEscapedBy) > 0 {
 		escFlavor = escapeFlavorMySQL
 		quoteStopSet = append(quoteStopSet, cfg.FieldEscapedBy[0])
-		unquoteStopSet = append(unquoteStopSet, cfg.FieldEscapedBy[0])
 		// we need special treatment of the NULL value \N, used by MySQL.
 		if !cfg.NotNull && slices.Contains(cfg.Null, cfg.FieldEscapedBy+`N`) {
 			escFlavor = escapeFlavorMySQLWithNull
 		}
-		r, err = regexp.Compile(`(?s)` + regexp.QuoteMeta(cfg.FieldEscapedBy) + `.`)
-		if err != nil {
-			return nil, err
-		}
 	}
 	return &CSVParser{
 		reader:            reader,
@@ -218,25 +487,84 @@ func NewCSVParser(
 		newLine:           []byte(terminator),
 		startingBy:        []byte(cfg.LineStartingBy),
 		escapedBy:         cfg.FieldEscapedBy,
-		unescapeRegexp:    r,
 		escFlavor:         escFlavor,
-		quoteByteSet:      makeByteSet(quoteStopSet),
-		unquoteByteSet:    makeByteSet(unquoteStopSet),
-		newLineByteSet:    makeByteSet(newLineStopSet),
+		quoteByteSet:      makeStopSet(quoteStopSet),
+		unquoteByteSet:    makeStopSet(unquoteStopSet),
+		newLineByteSet:    makeStopSet(newLineStopSet),
+		lineSep:           lineSep,
+		anyLineEnd:        len(terminator) == 0,
 		shouldParseHeader: shouldParseHeader,
+		shouldReadSepHint: cfg.SepHint,
 		allowEmptyLine:    cfg.AllowEmptyLine,
+		anyCRLF:           cfg.LineEnding == LineEndingAny,
 		quotedNullIsText:  cfg.QuotedNullIsText,
 		unescapedQuote:    cfg.UnescapedQuote,
 		reuseRow:          reuseRow,
 	}, nil
 }
+
+// newLineStopChars returns the first bytes of the line terminator.
+func newLineStopChars(terminator string) []byte {
+	if len(terminator) > 0 {
+		return []byte{terminator[0]}
+	}
+	// The character set encoding of '\r' and '\n' is the same in UTF-8 and GBK.
+	return []byte{'\r', '\n'}
+}
+
+// unquoteStopChars returns the first bytes of the tokens which are special
+// outside quoted fields.
+func unquoteStopChars(separator, delimiter string, newLineStopSet []byte, escapedBy string) []byte {
+	unquoteStopSet := []byte{separator[0]}
+	if len(delimiter) > 0 {
+		unquoteStopSet = append(unquoteStopSet, delimiter[0])
+	}
+	unquoteStopSet = append(unquoteStopSet, newLineStopSet...)
+	if len(escapedBy) > 0 {
+		unquoteStopSet = append(unquoteStopSet, escapedBy[0])
+	}
+	return unquoteStopSet
+}
+
+// NewCSVParserFromBytes creates a CSV parser which parses data in place, for
+// example the content of a memory-mapped file. No block is read or copied, and
+// the values which need no unescaping are sliced from data, so data must not
+// be modified or unmapped while the parser or the rows read are in use.
+func NewCSVParserFromBytes(
+	cfg *CSVConfig,
+	data []byte,
+	shouldParseHeader bool,
+	reuseRow bool,
+) (*CSVParser, error) {
+	parser, err := NewCSVParser(cfg, nil, 0, shouldParseHeader, reuseRow)
+	if err != nil {
+		return nil, err
+	}
+	parser.inMemory = true
+	parser.isLastChunk = true
+	parser.data = data
+	parser.input = unsafe.String(unsafe.SliceData(data), len(data))
+	parser.buf = bom.Clean(data)
+	parser.pos = int64(len(data) - len(parser.buf))
+	return parser, nil
+}
+
 func (parser *CSVParser) Read() (row []Field, err error) {
+	var (
+		start     time.Time
+		blockTime time.Duration
+	)
+	if parser.observer != nil {
+		start = time.Now()
+		blockTime = parser.progress.readBlockTime
+	}
 	if parser.reuseRow {
 		row, err = parser.readRow(parser.lastRow)
 		parser.lastRow = row
 	} else {
 		row, err = parser.readRow(nil)
 	}
+	parser.updateStats(start, blockTime, err)
 	return row, err
 }
 
@@ -244,8 +572,47 @@ func (parser *CSVParser) Pos() int64 {
 	return parser.pos
 }
 
+// ReadWithMeta is Read also returning where the row comes from. The slices of
+// the RowMeta are reused like the row when reuseRow is set.
+func (parser *CSVParser) ReadWithMeta() ([]Field, RowMeta, error) {
+	if !parser.trackLines {
+		parser.trackLines = true
+		parser.linesUnknown = parser.linesUnknown || parser.started
+	}
+	row, err := parser.Read()
+	if err != nil {
+		return nil, RowMeta{}, err
+	}
+	meta := RowMeta{
+		RowID:        parser.rowID,
+		Start:        parser.recordStart,
+		End:          parser.pos,
+		FieldOffsets: parser.fieldOffsets[:len(row)],
+		FieldQuoted:  parser.fieldIsQuoted[:len(row)],
+	}
+	if parser.digest != nil {
+		meta.Checksum, meta.HasChecksum = parser.checksum, true
+	}
+	if !parser.linesUnknown {
+		meta.Line = parser.recordLine
+	}
+	if !parser.reuseRow {
+		meta.FieldOffsets = slices.Clone(meta.FieldOffsets)
+		meta.FieldQuoted = slices.Clone(meta.FieldQuoted)
+	}
+	return row, meta, nil
+}
+
 // readRow reads a row from the datafile.
 func (parser *CSVParser) readRow(row []Field) ([]Field, error) {
+	parser.started = true
+	if parser.shouldReadSepHint {
+		err := parser.readSepHint()
+		if err != nil {
+			return nil, err
+		}
+		parser.shouldReadSepHint = false
+	}
 	// skip the header first
 	if parser.shouldParseHeader {
 		err := parser.readColumns()
@@ -255,18 +622,10 @@ func (parser *CSVParser) readRow(row []Field) ([]Field, error) {
 		parser.shouldParseHeader = false
 	}
 
-	records, err := parser.readRecord(parser.lastRecord)
+	records, err := parser.readFilteredRecord()
 	if err != nil {
 		return nil, err
 	}
-	parser.lastRecord = records
-	// remove the last empty value
-	if parser.cfg.TrimLastSep {
-		i := len(records) - 1
-		if i >= 0 && len(records[i].content) == 0 {
-			records = records[:i]
-		}
-	}
 	row = row[:0]
 	if cap(row) < len(records) {
 		row = make([]Field, len(records))
@@ -279,19 +638,74 @@ func (parser *CSVParser) readRow(row []Field) ([]Field, error) {
 		}
 		row[i].IsNull = isNull
 		row[i].Val = unescaped
+		if parser.cfg.KeepQuoting {
+			row[i].Quoted = record.quoted
+		}
+		if parser.cfg.KeepRaw {
+			row[i].Raw = record.content
+		}
+	}
+	if parser.digest != nil {
+		parser.checksum = RowChecksum(row)
+		parser.digest.AddChecksum(parser.checksum)
 	}
+	parser.rowID++
 
 	return row, nil
 }
 
+// readFilteredRecord reads the next record accepted by the filter, if any.
+// The fields only alias recordBuffer while the filter is called, so that a
+// rejected row allocates nothing.
+func (parser *CSVParser) readFilteredRecord() ([]field, error) {
+	for {
+		if err := parser.tokenizeRecord(); err != nil {
+			return nil, err
+		}
+		var str string
+		if parser.filter != nil {
+			str = unsafe.String(unsafe.SliceData(parser.recordBuffer), len(parser.recordBuffer))
+		} else {
+			str = parser.recordString()
+		}
+		records := parser.splitRecord(parser.lastRecord, str)
+		parser.lastRecord = records
+		records = parser.trimLastSep(records)
+		if parser.filter == nil {
+			return records, nil
+		}
+		if parser.filter(RawRow{parser: parser, records: records}) {
+			return parser.trimLastSep(parser.splitRecord(records, parser.recordString())), nil
+		}
+		// the rejected rows are still numbered, so that RowMeta.RowID is the
+		// number of the row in the input.
+		parser.rowID++
+		parser.progress.filteredRows++
+	}
+}
+
+// trimLastSep removes the last empty value when TrimLastSep is set.
+func (parser *CSVParser) trimLastSep(records []field) []field {
+	if parser.cfg.TrimLastSep {
+		i := len(records) - 1
+		if i >= 0 && len(records[i].content) == 0 {
+			records = records[:i]
+		}
+	}
+	return records
+}
+
 func (parser *CSVParser) unescapeString(input field) (unescaped string, isNull bool, err error) {
 	// Convert the input from another charset to utf8mb4 before we return the string.
-	unescaped = input.content
+	unescaped = parser.trimSpace(input)
+	if input.quoted && parser.cfg.NormalizeQuotedNewLines {
+		unescaped = normalizeNewLines(unescaped)
+	}
 	if parser.escFlavor == escapeFlavorMySQLWithNull && unescaped == parser.escapedBy+`N` {
-		return input.content, true, nil
+		return unescaped, true, nil
 	}
 	if len(parser.escapedBy) > 0 {
-		unescaped = unescape(unescaped, "", parser.escFlavor, parser.escapedBy[0], parser.unescapeRegexp)
+		unescaped = unescape(unescaped, "", parser.escFlavor, parser.escapedBy[0])
 	}
 	if !(len(parser.quote) > 0 && parser.quotedNullIsText && input.quoted) {
 		// this branch represents "quote is not configured" or "quoted null is null" or "this field has no quote"
@@ -306,6 +720,40 @@ func (parser *CSVParser) unescapeString(input field) (unescaped string, isNull b
 	return
 }
 
+// normalizeNewLines replaces the "\r\n" and "\r" of s by "\n".
+func normalizeNewLines(s string) string {
+	if strings.IndexByte(s, '\r') < 0 {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// trimSpace returns the content of the field trimmed according to the
+// TrimSpace config.
+func (parser *CSVParser) trimSpace(input field) string {
+	content := input.content
+	mode := parser.cfg.TrimSpace
+	if mode == TrimSpaceNone || (input.quoted && !parser.cfg.TrimQuotedSpace) {
+		return content
+	}
+	if mode&TrimSpaceLeading != 0 {
+		content = strings.TrimLeft(content, trimSpaceCutset)
+	}
+	if mode&TrimSpaceTrailing != 0 {
+		trimmed := strings.TrimRight(content, trimSpaceCutset)
+		// keep an escaped space, like the one in `a\ `.
+		if len(trimmed) < len(content) && parser.escFlavor != escapeFlavorNone {
+			escapes := len(trimmed) - len(strings.TrimRight(trimmed, parser.escapedBy[:1]))
+			if escapes%2 == 1 {
+				trimmed = content[:len(trimmed)+1]
+			}
+		}
+		content = trimmed
+	}
+	return content
+}
+
 // csvToken is a type representing either a normal byte or some CSV-specific
 // tokens such as the separator (comma), delimiter (quote) and terminator (new
 // line).
@@ -335,6 +783,7 @@ func (parser *CSVParser) readByte() (byte, error) {
 		return 0, io.EOF
 	}
 	b := parser.buf[0]
+	parser.countLines(parser.buf[:1])
 	parser.buf = parser.buf[1:]
 	parser.pos++
 	return b, nil
@@ -356,10 +805,48 @@ func (parser *CSVParser) peekBytes(cnt int) ([]byte, error) {
 }
 
 func (parser *CSVParser) skipBytes(n int) {
+	parser.countLines(parser.buf[:n])
 	parser.buf = parser.buf[n:]
 	parser.pos += int64(n)
 }
 
+// countLines counts the lines ended in consumed, if the lines are tracked.
+func (parser *CSVParser) countLines(consumed []byte) {
+	if !parser.trackLines || len(consumed) == 0 {
+		return
+	}
+	if !parser.anyLineEnd {
+		parser.lines += int64(bytes.Count(consumed, parser.lineSep))
+		return
+	}
+	parser.lines += countLineEnds(consumed, parser.lastCR)
+	parser.lastCR = consumed[len(consumed)-1] == '\r'
+}
+
+// uncountLines undoes countLines for the bytes put back into buf, which
+// don't follow a '\r'.
+func (parser *CSVParser) uncountLines(putBack []byte) {
+	if !parser.trackLines || len(putBack) == 0 {
+		return
+	}
+	if !parser.anyLineEnd {
+		parser.lines -= int64(bytes.Count(putBack, parser.lineSep))
+		return
+	}
+	parser.lines -= countLineEnds(putBack, false)
+	parser.lastCR = false
+}
+
+// countLineEnds counts the "\n", "\r\n" and lone '\r' line ends in b, prevCR
+// tells whether the byte before b is a '\r'.
+func countLineEnds(b []byte, prevCR bool) int64 {
+	n := bytes.Count(b, []byte{'\n'}) + bytes.Count(b, []byte{'\r'}) - bytes.Count(b, []byte("\r\n"))
+	if prevCR && b[0] == '\n' {
+		n--
+	}
+	return int64(n)
+}
+
 // tryPeekExact peeks the bytes ahead, and if it matches `content` exactly will
 // return (true, false, nil). If meet EOF it will return (false, true, nil).
 // For other errors it will return (false, false, err).
@@ -390,6 +877,11 @@ func (parser *CSVParser) tryReadExact(content []byte) (bool, error) {
 
 func (parser *CSVParser) tryReadNewLine(b byte) (bool, error) {
 	if len(parser.newLine) == 0 {
+		if b == '\r' && parser.anyCRLF {
+			// the '\n' of a "\r\n" is part of the same terminator.
+			_, err := parser.tryReadExact([]byte{'\n'})
+			return true, err
+		}
 		return b == '\r' || b == '\n', nil
 	}
 	if b != parser.newLine[0] {
@@ -462,19 +954,21 @@ func (parser *CSVParser) readUnquoteToken(b byte) (csvToken, error) {
 
 func (parser *CSVParser) appendCSVTokenToRecordBuffer(token csvToken) {
 	if token&csvTokenEscaped != 0 {
-		parser.recordBuffer = append(parser.recordBuffer, parser.escapedBy[0])
+		parser.appendInput([]byte{parser.escapedBy[0], byte(token)})
+		return
 	}
-	parser.recordBuffer = append(parser.recordBuffer, byte(token))
+	parser.appendInput([]byte{byte(token)})
 }
 
 // readUntil reads the buffer until any character from the `chars` set is found.
 // that character is excluded from the final buffer.
-func (parser *CSVParser) readUntil(chars *byteSet) ([]byte, byte, error) {
-	index := IndexAnyByte(parser.buf, chars)
+func (parser *CSVParser) readUntil(chars *stopSet) ([]byte, byte, error) {
+	index := chars.index(parser.buf)
 	if index >= 0 {
 		ret := parser.buf[:index]
 		parser.buf = parser.buf[index:]
 		parser.pos += int64(index)
+		parser.countLines(ret)
 		return ret, parser.buf[0], nil
 	}
 
@@ -491,22 +985,47 @@ func (parser *CSVParser) readUntil(chars *byteSet) ([]byte, byte, error) {
 				err = io.EOF
 			}
 			parser.pos += int64(len(buf))
+			parser.countLines(buf)
 			return buf, 0, err
 		}
-		index := IndexAnyByte(parser.buf, chars)
+		index := chars.index(parser.buf)
 		if index >= 0 {
 			buf = append(buf, parser.buf[:index]...)
 			parser.buf = parser.buf[index:]
 			parser.pos += int64(len(buf))
+			parser.countLines(buf)
 			return buf, parser.buf[0], nil
 		}
 	}
 }
 
 func (parser *CSVParser) readRecord(dst []field) ([]field, error) {
+	if err := parser.tokenizeRecord(); err != nil {
+		return nil, err
+	}
+	// Create a single string and create slices out of it.
+	// This pins the memory of the fields together, but allocates once.
+	return parser.splitRecord(dst, parser.recordString()), nil
+}
+
+// recordString returns recordBuffer as a string for splitRecord, it's not
+// allocated when every field is sliced from the input.
+func (parser *CSVParser) recordString() string {
+	if parser.inMemory && !slices.Contains(parser.fieldSources, -1) {
+		return ""
+	}
+	return string(parser.recordBuffer)
+}
+
+// tokenizeRecord reads the next record into recordBuffer, fieldIndexes,
+// fieldIsQuoted and fieldOffsets.
+func (parser *CSVParser) tokenizeRecord() error {
+	parser.started = true
 	parser.recordBuffer = parser.recordBuffer[:0]
 	parser.fieldIndexes = parser.fieldIndexes[:0]
 	parser.fieldIsQuoted = parser.fieldIsQuoted[:0]
+	parser.fieldOffsets = parser.fieldOffsets[:0]
+	parser.fieldSources = parser.fieldSources[:0]
 
 	isEmptyLine := true
 	whitespaceLine := true
@@ -514,6 +1033,11 @@ func (parser *CSVParser) readRecord(dst []field) ([]field, error) {
 	prevToken := csvTokenNewLine
 	fieldIsQuoted := false
 	var firstToken csvToken
+	// lineStart is where the current line starts, to count the skipped bytes,
+	// lineLines the lines before it and fieldStart where the field starts.
+	lineStart := parser.pos
+	lineLines := parser.lines
+	fieldStart := lineStart
 
 outside:
 	for {
@@ -529,19 +1053,29 @@ outside:
 			content, _, err := parser.readUntilTerminator()
 			if err != nil {
 				if len(content) == 0 {
-					return nil, err
+					return err
 				}
 				// if we reached EOF, we should still check the content contains
 				// startingBy and try to put back and parse it.
 			}
 			idx := bytes.Index(content, parser.startingBy)
 			if idx == -1 {
+				parser.progress.skippedBytes += parser.pos - oldPos
+				lineStart, lineLines, fieldStart = parser.pos, parser.lines, parser.pos
 				continue
 			}
+			parser.progress.skippedBytes += int64(idx)
 			foundStartingByThisLine = true
 			content = content[idx+len(parser.startingBy):]
-			parser.buf = append(content, parser.buf...)
 			parser.pos = oldPos + int64(idx+len(parser.startingBy))
+			if parser.inMemory {
+				// the rest of the line is still in data, and content aliases it.
+				parser.buf = parser.data[parser.pos:]
+			} else {
+				parser.buf = append(content, parser.buf...)
+			}
+			parser.uncountLines(content)
+			lineStart, lineLines, fieldStart = parser.pos, parser.lines, parser.pos
 		}
 
 		content, firstByte, err := parser.readUntil(&parser.unquoteByteSet)
@@ -549,15 +1083,20 @@ outside:
 		if len(content) > 0 {
 			isEmptyLine = false
 			if prevToken == csvTokenDelimiter {
-				return nil, errUnexpectedQuoteField
+				// spaces between a closing quote and the separator are
+				// allowed when they are trimmed anyway.
+				if parser.cfg.TrimSpace&TrimSpaceTrailing == 0 || !isBlank(content) {
+					return errUnexpectedQuoteField
+				}
+			} else {
+				parser.appendInput(content)
+				prevToken = csvTokenAnyUnquoted
 			}
-			parser.recordBuffer = append(parser.recordBuffer, content...)
-			prevToken = csvTokenAnyUnquoted
 		}
 
 		if err != nil {
 			if isEmptyLine || err != io.EOF {
-				return nil, err
+				return err
 			}
 			// treat EOF as the same as trailing \n.
 			firstToken = csvTokenNewLine
@@ -565,27 +1104,39 @@ outside:
 			parser.skipBytes(1)
 			firstToken, err = parser.readUnquoteToken(firstByte)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 
 		switch firstToken {
 		case csvTokenComma:
 			whitespaceLine = false
-			parser.fieldIndexes = append(parser.fieldIndexes, len(parser.recordBuffer))
-			parser.fieldIsQuoted = append(parser.fieldIsQuoted, fieldIsQuoted)
+			parser.endField(fieldIsQuoted, fieldStart)
 			fieldIsQuoted = false
+			fieldStart = parser.pos
 		case csvTokenDelimiter:
 			if prevToken != csvTokenComma && prevToken != csvTokenNewLine {
+				// a prefix is only dropped before the first quoted part of the
+				// field, a quoted part is never dropped.
+				if current := parser.currentField(); !fieldIsQuoted && parser.isQuotePrefix(current) {
+					// drop what's before the quote and read the quoted field.
+					parser.recordBuffer = parser.recordBuffer[:len(parser.recordBuffer)-len(current)]
+					if err = parser.readQuotedField(); err != nil {
+						return err
+					}
+					fieldIsQuoted = true
+					whitespaceLine = false
+					break
+				}
 				if parser.unescapedQuote {
 					whitespaceLine = false
-					parser.recordBuffer = append(parser.recordBuffer, parser.quote...)
+					parser.appendInput(parser.quote)
 					continue
 				}
-				return nil, errUnexpectedQuoteField
+				return errUnexpectedQuoteField
 			}
 			if err = parser.readQuotedField(); err != nil {
-				return nil, err
+				return err
 			}
 			fieldIsQuoted = true
 			whitespaceLine = false
@@ -595,30 +1146,40 @@ outside:
 			prevToken = firstToken
 			if !parser.allowEmptyLine {
 				if isEmptyLine {
+					parser.progress.skippedBytes += parser.pos - lineStart
+					lineStart, lineLines, fieldStart = parser.pos, parser.lines, parser.pos
 					continue
 				}
 				// skip lines only contain whitespaces
 				if err == nil && whitespaceLine && len(bytes.TrimSpace(parser.recordBuffer)) == 0 {
 					parser.recordBuffer = parser.recordBuffer[:0]
+					// the next line starts empty, or EOF would end a phantom row.
+					isEmptyLine = true
+					parser.progress.skippedBytes += parser.pos - lineStart
+					lineStart, lineLines, fieldStart = parser.pos, parser.lines, parser.pos
 					continue
 				}
 			}
-			parser.fieldIndexes = append(parser.fieldIndexes, len(parser.recordBuffer))
-			parser.fieldIsQuoted = append(parser.fieldIsQuoted, fieldIsQuoted)
+			parser.endField(fieldIsQuoted, fieldStart)
+			parser.recordStart = lineStart
+			parser.recordLine = lineLines + 1
 			// the loop is end, no need to reset fieldIsQuoted
 			break outside
 		default:
 			if prevToken == csvTokenDelimiter {
-				return nil, errUnexpectedQuoteField
+				return errUnexpectedQuoteField
 			}
 			parser.appendCSVTokenToRecordBuffer(firstToken)
 		}
 		prevToken = firstToken
 		isEmptyLine = false
 	}
-	// Create a single string and create slices out of it.
-	// This pins the memory of the fields together, but allocates once.
-	str := string(parser.recordBuffer) // Convert to string once to batch allocations
+	return nil
+}
+
+// splitRecord slices the fields of the record out of str, which holds the
+// content of recordBuffer.
+func (parser *CSVParser) splitRecord(dst []field, str string) []field {
 	dst = dst[:0]
 	if cap(dst) < len(parser.fieldIndexes) {
 		dst = make([]field, len(parser.fieldIndexes))
@@ -626,13 +1187,83 @@ outside:
 	dst = dst[:len(parser.fieldIndexes)]
 	var preIdx int
 	for i, idx := range parser.fieldIndexes {
-		dst[i].content = str[preIdx:idx]
+		if src := parser.sourceOf(i); src >= 0 {
+			dst[i].content = parser.input[src : src+int64(idx-preIdx)]
+		} else {
+			dst[i].content = str[preIdx:idx]
+		}
 		dst[i].quoted = parser.fieldIsQuoted[i]
 		preIdx = idx
 	}
 
 	// Check or update the expected fields per field.
-	return dst, nil
+	return dst
+}
+
+// sourceOf returns where the content of the i-th field is in the input, or -1
+// if it's not sliced from the input.
+func (parser *CSVParser) sourceOf(i int) int64 {
+	if !parser.inMemory {
+		return -1
+	}
+	return parser.fieldSources[i]
+}
+
+// appendInput appends to the field being read the bytes just consumed, which
+// end at pos. In memory, it also tracks whether the field is still the same
+// as the input, see fieldSources.
+func (parser *CSVParser) appendInput(b []byte) {
+	if parser.inMemory && len(b) > 0 {
+		start := parser.pos - int64(len(b))
+		n := int64(len(parser.currentField()))
+		switch {
+		case n == 0:
+			parser.fieldSource = start
+		case parser.fieldSource >= 0 && parser.fieldSource+n != start:
+			// something was skipped, like the second quote of a doubled one.
+			parser.fieldSource = -1
+		}
+	}
+	parser.recordBuffer = append(parser.recordBuffer, b...)
+}
+
+// endField ends the field being read in recordBuffer.
+func (parser *CSVParser) endField(quoted bool, offset int64) {
+	if parser.inMemory {
+		source := parser.fieldSource
+		if len(parser.currentField()) == 0 {
+			source = 0
+		}
+		parser.fieldSources = append(parser.fieldSources, source)
+	}
+	parser.fieldIndexes = append(parser.fieldIndexes, len(parser.recordBuffer))
+	parser.fieldIsQuoted = append(parser.fieldIsQuoted, quoted)
+	parser.fieldOffsets = append(parser.fieldOffsets, offset)
+}
+
+// currentField returns the content of the field being read in recordBuffer.
+func (parser *CSVParser) currentField() []byte {
+	fieldStart := 0
+	if n := len(parser.fieldIndexes); n > 0 {
+		fieldStart = parser.fieldIndexes[n-1]
+	}
+	return parser.recordBuffer[fieldStart:]
+}
+
+// isQuotePrefix reports whether content, found before an opening quote, can
+// be dropped: it's either the '=' of an Excel text formula like ="00123", or
+// spaces which are trimmed anyway.
+func (parser *CSVParser) isQuotePrefix(content []byte) bool {
+	if parser.cfg.UnwrapFormulaText && string(content) == "=" {
+		return true
+	}
+	return parser.cfg.TrimSpace&TrimSpaceLeading != 0 && isBlank(content)
+}
+
+// isBlank reports whether b only contains the spaces and tabs which TrimSpace
+// removes.
+func isBlank(b []byte) bool {
+	return len(bytes.Trim(b, trimSpaceCutset)) == 0
 }
 
 func (parser *CSVParser) readQuotedField() error {
@@ -651,7 +1282,7 @@ func (parser *CSVParser) readQuotedField() error {
 			}
 			return err
 		}
-		parser.recordBuffer = append(parser.recordBuffer, content...)
+		parser.appendInput(content)
 		parser.skipBytes(1)
 
 		token, err := parser.readQuotedToken(terminator)
@@ -668,7 +1299,7 @@ func (parser *CSVParser) readQuotedField() error {
 			}
 			if doubledDelimiter {
 				// consume the double quotation mark and continue
-				parser.recordBuffer = append(parser.recordBuffer, parser.quote...)
+				parser.appendInput(parser.quote)
 			} else if parser.unescapedQuote {
 				// allow unescaped quote inside quoted field, so we only finish
 				// reading the field when we see a delimiter + comma/newline.
@@ -683,7 +1314,7 @@ func (parser *CSVParser) readQuotedField() error {
 				if err2 != nil {
 					return err2
 				}
-				parser.recordBuffer = append(parser.recordBuffer, parser.quote...)
+				parser.appendInput(parser.quote)
 			} else {
 				// the field is completed, exit.
 				return nil
@@ -701,6 +1332,49 @@ func (parser *CSVParser) replaceEOF(err error, replaced error) error {
 	return replaced
 }
 
+// readSepHint consumes the `sep=X` line which Excel may write as the first
+// line of a file, and uses X as the separator from now on.
+func (parser *CSVParser) readSepHint() error {
+	const prefix = "sep="
+	bs, err := parser.peekBytes(len(prefix))
+	if err != nil {
+		return parser.replaceEOF(err, nil)
+	}
+	if !strings.EqualFold(string(bs), prefix) {
+		return nil
+	}
+	content, _, err := parser.readUntilTerminator()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	separator := bytes.TrimRight(content[len(prefix):], "\r\n")
+	if len(parser.newLine) > 0 {
+		separator = bytes.TrimSuffix(content[len(prefix):], parser.newLine)
+	}
+	if len(separator) == 0 {
+		return errors.New("syntax error: empty separator in the sep= line")
+	}
+	// the hinted separator must be as valid as a configured one, and it can't
+	// span lines, which happens when the line doesn't end with the terminator.
+	if bytes.ContainsAny(separator, "\r\n") {
+		return fmt.Errorf("syntax error: invalid separator %q in the sep= line", separator)
+	}
+	cfg := *parser.cfg
+	cfg.FieldTerminatedBy = string(separator)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("syntax error: invalid separator %q in the sep= line: %w", separator, err)
+	}
+	parser.setComma(separator)
+	return nil
+}
+
+// setComma replaces the separator of the config.
+func (parser *CSVParser) setComma(separator []byte) {
+	parser.comma = separator
+	parser.unquoteByteSet = makeStopSet(unquoteStopChars(
+		string(separator), string(parser.quote), newLineStopChars(string(parser.newLine)), parser.escapedBy))
+}
+
 // readColumns reads the columns of this CSV file.
 func (parser *CSVParser) readColumns() error {
 	columns, err := parser.readRecord(nil)
@@ -728,26 +1402,101 @@ func (parser *CSVParser) readColumns() error {
 // - error
 // Note that the terminator string pattern may be the content of a field, which
 // means it's inside quotes. Caller should make sure to handle this case.
+//
+// In memory, the content is the slice of data consumed, so it isn't copied and
+// must not be modified.
 func (parser *CSVParser) readUntilTerminator() ([]byte, int64, error) {
+	start := parser.pos
 	var ret []byte
+	collect := func(b ...byte) {
+		if !parser.inMemory {
+			ret = append(ret, b...)
+		}
+	}
 	for {
 		content, firstByte, err := parser.readUntil(&parser.newLineByteSet)
-		ret = append(ret, content...)
+		collect(content...)
 		if err != nil {
-			return ret, parser.pos, err
+			return parser.consumed(start, ret), parser.pos, err
 		}
 		parser.skipBytes(1)
-		ret = append(ret, firstByte)
+		collect(firstByte)
+		pos := parser.pos
 		if ok, err := parser.tryReadNewLine(firstByte); ok || err != nil {
 			if len(parser.newLine) >= 1 {
-				ret = append(ret, parser.newLine[1:]...)
+				collect(parser.newLine[1:]...)
+			} else if parser.pos > pos {
+				collect('\n')
 			}
-			return ret, parser.pos, err
+			return parser.consumed(start, ret), parser.pos, err
+		}
+	}
+}
+
+// consumed returns the bytes consumed since start: collected, a copy of them,
+// or the slice of data in memory.
+func (parser *CSVParser) consumed(start int64, read []byte) []byte {
+	if parser.inMemory {
+		return parser.data[start:parser.pos]
+	}
+	return read
+}
+
+// seek moves the parser to offset of the input and drops any buffered data.
+// The offset must be the start of a row, and the header, if any, is treated as
+// already parsed. The `sep=` line is read first if it's not yet.
+func (parser *CSVParser) seek(offset int64) error {
+	if parser.shouldReadSepHint && offset > 0 {
+		if err := parser.seek(0); err != nil {
+			return err
+		}
+		if err := parser.readSepHint(); err != nil {
+			return err
+		}
+		parser.shouldReadSepHint = false
+	}
+	switch {
+	case parser.inMemory:
+		if offset < 0 || offset > int64(len(parser.data)) {
+			return fmt.Errorf("offset %d is out of range [0, %d]", offset, len(parser.data))
+		}
+		parser.buf = parser.data[offset:]
+		if offset == 0 {
+			parser.buf = bom.Clean(parser.data)
+			offset = int64(len(parser.data) - len(parser.buf))
 		}
+	default:
+		seeker, ok := parser.reader.(io.Seeker)
+		if !ok {
+			return errors.New("the underlying reader does not support seeking")
+		}
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		parser.buf = nil
+		parser.isLastChunk = false
 	}
+	parser.pos = offset
+	parser.lines = 0
+	parser.lastCR = false
+	parser.started = false
+	parser.linesUnknown = offset > 0
+	parser.shouldParseHeader = false
+	return nil
 }
 
 func (parser *CSVParser) readBlock() error {
+	if parser.inMemory {
+		// all data is already in parser.buf.
+		return nil
+	}
+	if parser.observer != nil {
+		start := time.Now()
+		defer func() {
+			parser.progress.readBlockTime += time.Since(start)
+		}()
+	}
+	parser.progress.blocksRead++
 	n, err := io.ReadFull(parser.reader, parser.blockBuf)
 
 	switch {
@@ -788,7 +1537,6 @@ func unescape(
 	delim string,
 	escFlavor escapeFlavor,
 	escChar byte,
-	unescapeRegexp *regexp.Regexp,
 ) string {
 	if len(delim) > 0 {
 		delim2 := delim + delim
@@ -796,27 +1544,46 @@ func unescape(
 			input = strings.ReplaceAll(input, delim2, delim)
 		}
 	}
-	if escFlavor != escapeFlavorNone && strings.IndexByte(input, escChar) != -1 {
-		input = unescapeRegexp.ReplaceAllStringFunc(input, func(substr string) string {
-			switch substr[1] {
-			case '0':
-				return "\x00"
-			case 'b':
-				return "\b"
-			case 'n':
-				return "\n"
-			case 'r':
-				return "\r"
-			case 't':
-				return "\t"
-			case 'Z':
-				return "\x1a"
-			default:
-				return substr[1:]
-			}
-		})
+	if escFlavor == escapeFlavorNone {
+		return input
 	}
-	return input
+	idx := strings.IndexByte(input, escChar)
+	if idx == -1 {
+		return input
+	}
+	// Unescape in a single pass. The escape character consumes the whole UTF-8
+	// sequence following it, and a trailing escape character is kept as is.
+	var sb strings.Builder
+	sb.Grow(len(input))
+	sb.WriteString(input[:idx])
+	for i := idx; i < len(input); i++ {
+		c := input[i]
+		if c != escChar || i+1 == len(input) {
+			sb.WriteByte(c)
+			continue
+		}
+		switch input[i+1] {
+		case '0':
+			sb.WriteByte(0)
+		case 'b':
+			sb.WriteByte('\b')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'Z':
+			sb.WriteByte(0x1a)
+		default:
+			_, size := utf8.DecodeRuneInString(input[i+1:])
+			sb.WriteString(input[i+1 : i+1+size])
+			i += size
+			continue
+		}
+		i++
+	}
+	return sb.String()
 }
 
 // Copyright 2009 The Go Authors. All rights reserved.
@@ -856,3 +1623,89 @@ func IndexAnyByte(s []byte, as *byteSet) int {
 	}
 	return -1
 }
+
+// swarMaxChars is the largest stop set searched a word at a time. It covers
+// the first bytes of the quote, separator, terminator and escape.
+const swarMaxChars = 4
+
+const (
+	swarLo uint64 = 0x0101010101010101
+	swarHi uint64 = 0x8080808080808080
+)
+
+// stopSet is a byteSet which also remembers its members when there are few
+// enough of them to use indexAnyByteSWAR, or the vector search of the
+// architecture, see indexSmall.
+type stopSet struct {
+	set byteSet
+	// chars holds the distinct members of set, it is nil if there are more
+	// than swarMaxChars of them. packed holds them one per byte, the first
+	// one repeated up to swarMaxChars.
+	chars  []byte
+	packed uint32
+}
+
+// makeStopSet creates a stopSet of byte value.
+func makeStopSet(chars []byte) stopSet {
+	s := stopSet{set: makeByteSet(chars)}
+	for _, c := range chars {
+		if bytes.IndexByte(s.chars, c) == -1 {
+			s.chars = append(s.chars, c)
+		}
+	}
+	if len(s.chars) > swarMaxChars {
+		s.chars = nil
+	}
+	for i := 0; i < swarMaxChars && len(s.chars) > 0; i++ {
+		c := s.chars[0]
+		if i < len(s.chars) {
+			c = s.chars[i]
+		}
+		s.packed |= uint32(c) << (8 * i)
+	}
+	return s
+}
+
+// index returns the byte index of the first occurrence in b of any byte in
+// the set, or -1 if there is none.
+func (s *stopSet) index(b []byte) int {
+	if len(s.chars) > 0 {
+		return s.indexSmall(b)
+	}
+	return IndexAnyByte(b, &s.set)
+}
+
+// indexAnyByteSWAR is IndexAnyByte for at most swarMaxChars bytes, comparing
+// eight bytes at a time. It uses the classic "has zero byte" trick on the word
+// xor-ed with each repeated char: (x - 0x01..01) & ^x & 0x80..80 is non-zero
+// iff x has a zero byte, and its lowest set bit marks the first one.
+func indexAnyByteSWAR(s []byte, chars []byte) int {
+	var masks [swarMaxChars]uint64
+	for i, c := range chars {
+		masks[i] = swarLo * uint64(c)
+	}
+	// repeat the first char so the loop below always checks four masks,
+	// which is faster than looping over len(chars).
+	for i := len(chars); i < swarMaxChars; i++ {
+		masks[i] = masks[0]
+	}
+
+	i := 0
+	for ; i+8 <= len(s); i += 8 {
+		w := binary.LittleEndian.Uint64(s[i:])
+		x0 := w ^ masks[0]
+		x1 := w ^ masks[1]
+		x2 := w ^ masks[2]
+		x3 := w ^ masks[3]
+		found := (x0-swarLo)&^x0 | (x1-swarLo)&^x1 | (x2-swarLo)&^x2 | (x3-swarLo)&^x3
+		if found &= swarHi; found != 0 {
+			return i + bits.TrailingZeros64(found)>>3
+		}
+	}
+	for ; i < len(s); i++ {
+		if bytes.IndexByte(chars, s[i]) != -1 {
+			return i
+		}
+	}
+	return -1
+}
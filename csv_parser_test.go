@@ -4,8 +4,10 @@ import (
 	mydump "csvReader"
 	"github.com/stretchr/testify/require"
 	"io"
+	"regexp"
 	"strings"
 	"testing"
+	"unsafe"
 )
 
 // TODO: rewrite test case
@@ -446,3 +448,609 @@ func TestCustomEscapeChar(t *testing.T) {
 		newStringField(`{"itemRangeType":0,"itemContainType":0,"shopRangeType":1,"shopJson":"[{\"id\":\"A1234\",\"shopName\":\"AAAAAA\"}]"}`, false),
 	}, row)
 }
+
+// regexpUnescape is the regexp based unescaping the parser used to do, kept
+// here as the reference for the single-pass implementation.
+func regexpUnescape(input string, escapedBy string) string {
+	r := regexp.MustCompile(`(?s)` + regexp.QuoteMeta(escapedBy) + `.`)
+	return r.ReplaceAllStringFunc(input, func(substr string) string {
+		switch substr[1] {
+		case '0':
+			return "\x00"
+		case 'b':
+			return "\b"
+		case 'n':
+			return "\n"
+		case 'r':
+			return "\r"
+		case 't':
+			return "\t"
+		case 'Z':
+			return "\x1a"
+		default:
+			return substr[1:]
+		}
+	})
+}
+
+func TestUnescapeMatchesRegexp(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		FieldEscapedBy:    `\`,
+	}
+
+	inputs := []string{
+		`plain`,
+		`\0\b\n\r\t\Z\\`,
+		`a\\\b`,
+		`\x\y\z`,
+		`\你好\🤔`,
+		"\\\xff\xfe",
+		`\"`,
+		`ends with \`,
+		`\\\\N`,
+	}
+	for _, input := range inputs {
+		quoted := `"` + input + `"`
+		parser, err := mydump.NewCSVParser(&cfg, NewStringReader(quoted), int64(mydump.ReadBlockSize), false, false)
+		require.NoError(t, err)
+		row, err := parser.Read()
+		if strings.HasSuffix(input, `\`) && !strings.HasSuffix(input, `\\`) {
+			// the escape character swallows the closing quote
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err, input)
+		require.Equal(t, []mydump.Field{
+			newStringField(regexpUnescape(input, `\`), false),
+		}, row, input)
+	}
+}
+
+func escapedTPCHInput(rows int) string {
+	datums := tpchDatums()
+	var b strings.Builder
+	for i := 0; i < rows; i++ {
+		for j, d := range datums[i%len(datums)] {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strings.NewReplacer(
+				" ", `\t`,
+				"#", `\\`,
+				"a", `\"a`,
+				"e", `\,e`,
+			).Replace(d.Val))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func benchmarkTPCH(b *testing.B, input string, cfg *mydump.CSVConfig) {
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser, err := mydump.NewCSVParser(cfg, NewStringReader(input), int64(mydump.ReadBlockSize), false, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			_, err = parser.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadTPCH(b *testing.B) {
+	input := datumsToString(tpchDatums(), ",", "", false)
+	input = strings.Repeat(input, 1000)
+	benchmarkTPCH(b, input, &mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEscapedBy:    `\`,
+	})
+}
+
+func BenchmarkReadTPCHFromBytes(b *testing.B) {
+	input := []byte(strings.Repeat(datumsToString(tpchDatums(), ",", "", false), 1000))
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEscapedBy:    `\`,
+	}
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser, err := mydump.NewCSVParserFromBytes(&cfg, input, false, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			_, err = parser.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadTPCHEscaped(b *testing.B) {
+	input := escapedTPCHInput(3000)
+	benchmarkTPCH(b, input, &mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEscapedBy:    `\`,
+	})
+}
+
+func FuzzIndexAnyByte(f *testing.F) {
+	f.Add([]byte("aaa,bbb,ccc\r\n"), []byte(",\r\n"))
+	f.Add([]byte(`"a\"b","c"`), []byte(`"\`))
+	f.Add([]byte("\x00\x80\xff\x7f\x01"), []byte{0x80, 0xff})
+	f.Add([]byte(strings.Repeat("x", 63)+"|"), []byte("|"))
+	f.Add([]byte(strings.Repeat("x", 47)+"\n"+strings.Repeat("y", 20)), []byte("|\"\r\n"))
+	f.Add([]byte(strings.Repeat("\xff", 33)), []byte{0x7f, 0xfe})
+	f.Fuzz(func(t *testing.T, s []byte, chars []byte) {
+		if len(chars) == 0 {
+			return
+		}
+		expected := mydump.IndexAnyByteSet(s, chars)
+		require.Equal(t, expected, mydump.IndexAnyByteStopSet(s, chars))
+		require.Equal(t, expected, mydump.IndexAnyByteSWAR(s, chars))
+	})
+}
+
+func benchmarkIndexAnyByte(b *testing.B, index func(s []byte, chars []byte) int) {
+	s := []byte(strings.Repeat("goldenrod lavender spring chocolate lace ", 100) + "|")
+	chars := []byte("|\"\r\n")
+	b.SetBytes(int64(len(s)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if index(s, chars) != len(s)-1 {
+			b.Fatal("wrong index")
+		}
+	}
+}
+
+func BenchmarkIndexAnyByte(b *testing.B) {
+	benchmarkIndexAnyByte(b, mydump.IndexAnyByteSet)
+}
+
+func BenchmarkIndexAnyByteSWAR(b *testing.B) {
+	benchmarkIndexAnyByte(b, mydump.IndexAnyByteSWAR)
+}
+
+func BenchmarkIndexAnyByteStopSet(b *testing.B) {
+	benchmarkIndexAnyByte(b, mydump.IndexAnyByteStopSet)
+}
+
+type parserResult struct {
+	row []mydump.Field
+	pos int64
+	err error
+}
+
+func readAllResults(t *testing.T, parser *mydump.CSVParser) []parserResult {
+	var results []parserResult
+	for {
+		row, err := parser.Read()
+		results = append(results, parserResult{row: row, pos: parser.Pos(), err: err})
+		if err != nil {
+			return results
+		}
+	}
+}
+
+func TestReaderAndBytesInputAgree(t *testing.T) {
+	rfc4180 := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+	}
+	mysql := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		LineTerminatedBy:  "\n",
+		FieldEscapedBy:    `\`,
+		Null:              []string{`\N`},
+		UnescapedQuote:    true,
+	}
+	startingBy := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		LineTerminatedBy:  "\n",
+		LineStartingBy:    "xxx",
+	}
+	tpch := mydump.CSVConfig{
+		FieldTerminatedBy: "|",
+		TrimLastSep:       true,
+	}
+	cases := []struct {
+		cfg    *mydump.CSVConfig
+		input  string
+		header bool
+	}{
+		{&rfc4180, "aaa,bbb,ccc\nzzz,yyy,xxx\n", false},
+		{&rfc4180, "a,b\n1,2\n3,4", true},
+		{&rfc4180, "\xEF\xBB\xBF\"aaa\",\"b\nbb\",\"c\"\"cc\"\r\n\r\nzzz", false},
+		{&rfc4180, `"unterminated`, false},
+		{&rfc4180, `"a"b,c`, false},
+		{&mysql, "\"\\\"\",\\N,\\\\N\n3,\"a \" quote\",102.20\n", false},
+		{&mysql, `dangling\`, false},
+		{&startingBy, "xxx1,2\nignored\nabcxxx3,4\n", false},
+		{&tpch, datumsToString(tpchDatums(), "|", "", true), false},
+		{&mysql, "\"a\"\"b\",c\\,d,\"e\"f\",\"\"\n", false},
+	}
+	for _, tc := range cases {
+		reader, err := mydump.NewCSVParser(tc.cfg, NewStringReader(tc.input), 4, tc.header, false)
+		require.NoError(t, err)
+		inMemory, err := mydump.NewCSVParserFromBytes(tc.cfg, []byte(tc.input), tc.header, false)
+		require.NoError(t, err)
+		require.Equal(t, readAllResults(t, reader), readAllResults(t, inMemory), tc.input)
+	}
+}
+
+func TestBytesInputIsSliced(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		FieldEscapedBy:    `\`,
+		TrimSpace:         mydump.TrimSpaceBoth,
+		KeepRaw:           true,
+	}
+	input := []byte("plain, \"quoted\",\"a\"\"b\",c\\,d,\n")
+	parser, err := mydump.NewCSVParserFromBytes(&cfg, input, false, false)
+	require.NoError(t, err)
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []string{"plain", "quoted", `a"b`, "c,d", ""}, []string{row[0].Val, row[1].Val, row[2].Val, row[3].Val, row[4].Val})
+
+	inInput := func(s string) bool {
+		p := uintptr(unsafe.Pointer(unsafe.StringData(s)))
+		start := uintptr(unsafe.Pointer(&input[0]))
+		return p >= start && p < start+uintptr(len(input))
+	}
+	// the values which need no unescaping are in the input.
+	require.True(t, inInput(row[0].Val))
+	require.True(t, inInput(row[1].Val))
+	require.False(t, inInput(row[2].Val))
+	require.False(t, inInput(row[3].Val))
+	require.True(t, inInput(row[3].Raw))
+
+	// so reading them allocates nothing.
+	input = []byte(strings.Repeat("aaa,\"bbb\",ccc\n", 100))
+	parser, err = mydump.NewCSVParserFromBytes(&cfg, input, false, true)
+	require.NoError(t, err)
+	allocs := testing.AllocsPerRun(50, func() {
+		_, err = parser.Read()
+		require.NoError(t, err)
+	})
+	require.Zero(t, allocs)
+
+	// the lines skipped or cut by LINES STARTING BY aren't copied either.
+	startingBy := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		LineTerminatedBy:  "\n",
+		LineStartingBy:    "xxx",
+	}
+	input = []byte(strings.Repeat("ignored\nabcxxx1,2\n", 100))
+	parser, err = mydump.NewCSVParserFromBytes(&startingBy, input, false, true)
+	require.NoError(t, err)
+	allocs = testing.AllocsPerRun(50, func() {
+		row, err = parser.Read()
+		require.NoError(t, err)
+	})
+	require.Zero(t, allocs)
+	require.Equal(t, []mydump.Field{newStringField("1", false), newStringField("2", false)}, row)
+}
+
+func TestInvalidSepHint(t *testing.T) {
+	cases := []struct {
+		cfg   mydump.CSVConfig
+		input string
+		err   string
+	}{
+		{*mydump.NewExcelConfig(), "sep=\r\na,b\r\n", "syntax error: empty separator in the sep= line"},
+		{*mydump.NewExcelConfig(), "sep=\na,b\n", "syntax error: empty separator in the sep= line"},
+		{*mydump.NewExcelConfig(), "sep=", "syntax error: empty separator in the sep= line"},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", LineTerminatedBy: "\n", SepHint: true}, "sep=\n", "syntax error: empty separator in the sep= line"},
+		{*mydump.NewExcelConfig(), "sep=\"\na,b\n",
+			`syntax error: invalid separator "\"" in the sep= line: FieldTerminatedBy "\"" and FieldEnclosedBy "\"" cannot be told apart`},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", FieldEscapedBy: `\`, SepHint: true}, "sep=\\\na\n",
+			`syntax error: invalid separator "\\" in the sep= line: FieldTerminatedBy "\\" and FieldEscapedBy "\\" cannot be told apart`},
+		// the line doesn't end with the terminator.
+		{mydump.CSVConfig{FieldTerminatedBy: ",", LineTerminatedBy: "\r\n", SepHint: true}, "sep=;\na;b\r\n",
+			`syntax error: invalid separator ";\na;b" in the sep= line`},
+	}
+	for _, tc := range cases {
+		parser, err := mydump.NewCSVParser(&tc.cfg, NewStringReader(tc.input), int64(mydump.ReadBlockSize), false, false)
+		require.NoError(t, err)
+		_, err = parser.Read()
+		require.EqualError(t, err, tc.err, tc.input)
+	}
+
+	// a valid hint replaces the configured separator.
+	cfg := mydump.NewExcelConfig()
+	parser, err := mydump.NewCSVParser(cfg, NewStringReader("sep=|\na|b\n"), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{newStringField("a", false), newStringField("b", false)}, row)
+}
+
+func TestWhitespaceLineAtEOF(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ","}
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader("a\n  \n"), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{newStringField("a", false)}, row)
+	_, err = parser.Read()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestTrimSpace(t *testing.T) {
+	input := " a , \"b \" ,\t\\N\t, \"\" ,c\\  \n"
+	cases := []struct {
+		input  string
+		mode   mydump.TrimSpaceMode
+		quoted bool
+		row    []mydump.Field
+	}{
+		{" a ,\"b \" ,\t\\N\t,\"\" ,c\\  \n", mydump.TrimSpaceTrailing, false, []mydump.Field{
+			newStringField(" a", false),
+			newStringField("b ", false),
+			newStringField("\tN", false),
+			newStringField("", false),
+			newStringField("c ", false),
+		}},
+		{input, mydump.TrimSpaceBoth, false, []mydump.Field{
+			newStringField("a", false),
+			newStringField("b ", false),
+			newStringField("\\N", true),
+			newStringField("", false),
+			newStringField("c ", false),
+		}},
+		{input, mydump.TrimSpaceBoth, true, []mydump.Field{
+			newStringField("a", false),
+			newStringField("b", false),
+			newStringField("\\N", true),
+			newStringField("", false),
+			newStringField("c ", false),
+		}},
+	}
+	for _, tc := range cases {
+		cfg := mydump.CSVConfig{
+			FieldTerminatedBy: ",",
+			FieldEnclosedBy:   `"`,
+			FieldEscapedBy:    `\`,
+			Null:              []string{`\N`},
+			TrimSpace:         tc.mode,
+			TrimQuotedSpace:   tc.quoted,
+		}
+		parser, err := mydump.NewCSVParser(&cfg, NewStringReader(tc.input), int64(mydump.ReadBlockSize), false, false)
+		require.NoError(t, err)
+		row, err := parser.Read()
+		require.NoError(t, err)
+		require.Equal(t, tc.row, row, tc.mode)
+	}
+
+	// without trimming, the spaces after a closing quote are an error.
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`, TrimSpace: mydump.TrimSpaceLeading}
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(`"a" ,b`), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	_, err = parser.Read()
+	require.Error(t, err)
+
+	// a quoted part is never dropped as the prefix of another one.
+	cfg = mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`, TrimSpace: mydump.TrimSpaceBoth}
+	for _, input := range []string{`"" "x",b`, `" " "x",b`} {
+		parser, err = mydump.NewCSVParser(&cfg, NewStringReader(input), int64(mydump.ReadBlockSize), false, false)
+		require.NoError(t, err)
+		_, err = parser.Read()
+		require.EqualError(t, err, "syntax error: cannot have consecutive fields without separator", input)
+	}
+}
+
+func TestLineEnding(t *testing.T) {
+	readVals := func(cfg *mydump.CSVConfig, input string) []string {
+		parser, err := mydump.NewCSVParser(cfg, NewStringReader(input), 4, false, false)
+		require.NoError(t, err)
+		var vals []string
+		for {
+			row, err := parser.Read()
+			if err == io.EOF {
+				return vals
+			}
+			require.NoError(t, err)
+			require.Len(t, row, 1)
+			vals = append(vals, row[0].Val)
+		}
+	}
+	input := "a\r\n\r\nb\nc\rd\r\n"
+	cases := []struct {
+		ending mydump.LineEnding
+		vals   []string
+	}{
+		{mydump.LineEndingDefault, []string{"a", "", "", "", "b", "c", "d", ""}},
+		{mydump.LineEndingAny, []string{"a", "", "b", "c", "d"}},
+		{mydump.LineEndingLF, []string{"a\r", "\r", "b", "c\rd\r"}},
+		{mydump.LineEndingCRLF, []string{"a", "", "b\nc\rd"}},
+		{mydump.LineEndingCR, []string{"a", "\n", "\nb\nc", "d", "\n"}},
+	}
+	for _, tc := range cases {
+		cfg := mydump.CSVConfig{FieldTerminatedBy: ",", AllowEmptyLine: true, LineEnding: tc.ending}
+		require.Equal(t, tc.vals, readVals(&cfg, input), tc.ending)
+	}
+
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`, LineEnding: mydump.LineEndingAny}
+	require.Equal(t, []string{"x\r\ny\rz", "w"}, readVals(&cfg, "\"x\r\ny\rz\"\r\nw"))
+	cfg.NormalizeQuotedNewLines = true
+	require.Equal(t, []string{"x\ny\nz", "w"}, readVals(&cfg, "\"x\r\ny\rz\"\r\nw"))
+
+	cfg.LineTerminatedBy = "\n"
+	_, err := mydump.NewCSVParser(&cfg, NewStringReader(""), 4, false, false)
+	require.Error(t, err)
+}
+
+func TestReadWithMeta(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`}
+	input := "id,v\r\n1,\"a\nb\"\n\n2, x\n"
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), 4, true, false)
+	require.NoError(t, err)
+
+	row, meta, err := parser.ReadWithMeta()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{newStringField("1", false), newStringField("a\nb", false)}, row)
+	require.Equal(t, mydump.RowMeta{
+		RowID:        1,
+		Start:        6,
+		End:          14,
+		Line:         2,
+		FieldOffsets: []int64{6, 8},
+		FieldQuoted:  []bool{false, true},
+	}, meta)
+	expected := mydump.RowMeta{
+		RowID:        2,
+		Start:        15,
+		End:          20,
+		Line:         5,
+		FieldOffsets: []int64{15, 17},
+		FieldQuoted:  []bool{false, false},
+	}
+	_, meta, err = parser.ReadWithMeta()
+	require.NoError(t, err)
+	require.Equal(t, expected, meta)
+	_, _, err = parser.ReadWithMeta()
+	require.Equal(t, io.EOF, err)
+
+	// after seeking, the rows are still numbered but the lines are unknown.
+	indexed, err := mydump.NewCSVParser(&cfg, NewStringReader(input), 4, true, false)
+	require.NoError(t, err)
+	idx, err := mydump.BuildRowIndex(indexed, 1)
+	require.NoError(t, err)
+	parser, err = mydump.NewCSVParserFromBytes(&cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	require.NoError(t, parser.SeekRow(idx, 1))
+	_, meta, err = parser.ReadWithMeta()
+	require.NoError(t, err)
+	expected.Line = 0
+	require.Equal(t, expected, meta)
+
+	// lines are only counted from the first ReadWithMeta.
+	parser, err = mydump.NewCSVParserFromBytes(&cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	_, err = parser.Read()
+	require.NoError(t, err)
+	_, meta, err = parser.ReadWithMeta()
+	require.NoError(t, err)
+	require.Equal(t, expected, meta)
+
+	// '\r', "\r\n" and '\n' each end a line, even across blocks.
+	for _, ending := range []mydump.LineEnding{mydump.LineEndingDefault, mydump.LineEndingAny} {
+		cfg := mydump.CSVConfig{FieldTerminatedBy: ",", LineEnding: ending}
+		parser, err = mydump.NewCSVParser(&cfg, NewStringReader("a\r\r\nbbb\rc\n\n\rd"), 1, false, false)
+		require.NoError(t, err)
+		var lines []int64
+		for {
+			_, meta, err := parser.ReadWithMeta()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			lines = append(lines, meta.Line)
+		}
+		require.Equal(t, []int64{1, 3, 4, 7}, lines, ending)
+	}
+}
+
+func TestKeepQuoting(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		FieldEscapedBy:    `\`,
+		LineTerminatedBy:  "\n",
+		Null:              []string{`\N`, "NULL"},
+		QuotedNullIsText:  true,
+		KeepQuoting:       true,
+		KeepRaw:           true,
+	}
+	input := "\"\",,\"123\",123,\"NULL\",NULL,\"a\\tb\"\"\"\n"
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		{Val: "", Quoted: true},
+		{Val: ""},
+		{Val: "123", Quoted: true, Raw: "123"},
+		{Val: "123", Raw: "123"},
+		{Val: "NULL", Quoted: true, Raw: "NULL"},
+		{Val: "NULL", IsNull: true, Raw: "NULL"},
+		{Val: "a\tb\"", Quoted: true, Raw: "a\\tb\""},
+	}, row)
+
+	// the writer and the JSON encoder preserve the quoting.
+	var out strings.Builder
+	writer := mydump.NewCSVWriter(&cfg, &out)
+	require.NoError(t, writer.Write(row))
+	require.NoError(t, writer.Flush())
+	require.Equal(t, "\"\",,\"123\",123,\"NULL\",\\N,\"a\tb\"\"\"\n", out.String())
+
+	out.Reset()
+	encoder := mydump.NewJSONLinesEncoder(&out, &mydump.JSONConfig{CoerceNumbers: true}, nil)
+	require.NoError(t, encoder.Write(row[2:4]))
+	require.NoError(t, encoder.Flush())
+	require.Equal(t, `["123",123]`+"\n", out.String())
+}
+
+func TestValidateConfig(t *testing.T) {
+	cases := []struct {
+		cfg mydump.CSVConfig
+		err string
+	}{
+		{mydump.CSVConfig{}, "FieldTerminatedBy cannot be empty"},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", FieldEscapedBy: `\\`}, `FieldEscapedBy '\\' must be a single byte`},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: ","}, `FieldTerminatedBy "," and FieldEnclosedBy ","`},
+		{mydump.CSVConfig{FieldTerminatedBy: "|", FieldEscapedBy: "|"}, `FieldTerminatedBy "|" and FieldEscapedBy "|"`},
+		{mydump.CSVConfig{FieldTerminatedBy: "|", FieldEnclosedBy: "||"}, `FieldTerminatedBy "|" and FieldEnclosedBy "||"`},
+		{mydump.CSVConfig{FieldTerminatedBy: "\r\n"}, `FieldTerminatedBy "\r\n" and the line ending "\r"`},
+		{mydump.CSVConfig{FieldTerminatedBy: "\n", LineEnding: mydump.LineEndingAny}, `FieldTerminatedBy "\n" and the line ending "\n"`},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", LineTerminatedBy: ",\n"}, `FieldTerminatedBy "," and LineTerminatedBy ",\n"`},
+		{mydump.CSVConfig{FieldTerminatedBy: "\n", LineEnding: mydump.LineEndingLF}, `FieldTerminatedBy "\n" and LineEnding "\n"`},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", LineTerminatedBy: "\n", LineEnding: mydump.LineEndingLF}, "cannot be set together with LineEnding"},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", LineTerminatedBy: "\n", LineStartingBy: "a\nb"}, "STARTING BY 'a\nb' cannot contain"},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", LineStartingBy: "a\rb"}, `STARTING BY "a\rb" cannot contain the line ending, '\r' or '\n'`},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", Null: []string{"a,b"}}, `Null value "a,b" can never match, it contains FieldTerminatedBy ","`},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`, QuotedNullIsText: true, Null: []string{"N\n"}}, `Null value "N\n" can never match, it contains the line ending "\n"`},
+		{mydump.CSVConfig{FieldTerminatedBy: ",", TrimSpace: mydump.TrimSpaceBoth, Null: []string{" NULL"}}, "TrimSpace removes its spaces"},
+	}
+	for _, tc := range cases {
+		err := tc.cfg.Validate()
+		require.Error(t, err, tc.err)
+		require.Contains(t, err.Error(), tc.err)
+		_, err = mydump.NewCSVParser(&tc.cfg, NewStringReader(""), 4, false, false)
+		require.Error(t, err, tc.err)
+	}
+
+	valid := []mydump.CSVConfig{
+		{FieldTerminatedBy: ","},
+		{FieldTerminatedBy: ",", FieldEnclosedBy: `"`, FieldEscapedBy: `\`, LineTerminatedBy: "\n", Null: []string{`\N`, "a,b"}},
+		{FieldTerminatedBy: ",", FieldEnclosedBy: `"`, Null: []string{"a,b", " NULL"}, TrimSpace: mydump.TrimSpaceBoth},
+		{FieldTerminatedBy: ",", Null: []string{"a,b"}, NotNull: true},
+		{FieldTerminatedBy: "\t", LineEnding: mydump.LineEndingCRLF},
+		{FieldTerminatedBy: ",", LineStartingBy: "xxx"},
+		{FieldTerminatedBy: ",", LineStartingBy: "xxx", LineEnding: mydump.LineEndingAny},
+	}
+	for _, cfg := range valid {
+		require.NoError(t, cfg.Validate())
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CSVWriter writes rows in the format described by a CSVConfig, so that a
+// CSVParser with the same config reads them back. Fields are only quoted when
+// needed, NULL fields are written as the escaped `\N` or the first Null value.
+type CSVWriter struct {
+	cfg *CSVConfig
+	w   *bufio.Writer
+
+	comma   string
+	quote   string
+	newLine string
+	escape  string
+	null    string
+	// special holds the bytes which must be quoted or escaped.
+	special string
+
+	buf []byte
+	// digest holds the rows written since EnableDigest.
+	digest *Digest
+	// escapeFormulas is set by SetEscapeFormulas.
+	escapeFormulas bool
+}
+
+// NewCSVWriter creates a CSV writer. An empty LineTerminatedBy is written as
+// the terminator of LineEnding, or "\r\n" as RFC 4180 suggests.
+func NewCSVWriter(cfg *CSVConfig, w io.Writer) *CSVWriter {
+	newLine := cfg.LineTerminatedBy
+	if len(newLine) == 0 {
+		newLine = lineEndingTerminators[cfg.LineEnding]
+	}
+	if len(newLine) == 0 {
+		newLine = "\r\n"
+	}
+	var null string
+	switch {
+	case cfg.NotNull:
+	case len(cfg.FieldEscapedBy) > 0 && slices.Contains(cfg.Null, cfg.FieldEscapedBy+`N`):
+		null = cfg.FieldEscapedBy + `N`
+	case len(cfg.Null) > 0:
+		null = cfg.Null[0]
+	}
+	special := "\r\n" + newLine[:1] + cfg.FieldTerminatedBy[:1]
+	if len(cfg.FieldEnclosedBy) > 0 {
+		special += cfg.FieldEnclosedBy[:1]
+	}
+	if len(cfg.FieldEscapedBy) > 0 {
+		special += cfg.FieldEscapedBy[:1]
+	}
+	return &CSVWriter{
+		cfg:     cfg,
+		w:       bufio.NewWriter(w),
+		comma:   cfg.FieldTerminatedBy,
+		quote:   cfg.FieldEnclosedBy,
+		newLine: newLine,
+		escape:  cfg.FieldEscapedBy,
+		null:    null,
+		special: special,
+	}
+}
+
+// NewExcelWriter creates a CSVWriter writing the format of NewExcelConfig,
+// with SetEscapeFormulas on so that Excel doesn't evaluate the values as
+// formulas.
+func NewExcelWriter(w io.Writer) *CSVWriter {
+	writer := NewCSVWriter(NewExcelConfig(), w)
+	writer.SetEscapeFormulas(true)
+	return writer
+}
+
+// SetEscapeFormulas makes Write prefix the values starting with '=', '+', '-'
+// or '@' with a single quote, so that spreadsheets don't evaluate them as
+// formulas. Numbers like -1 are left as is.
+func (w *CSVWriter) SetEscapeFormulas(escape bool) {
+	w.escapeFormulas = escape
+}
+
+// Write writes a row, the output is buffered until Flush is called.
+func (w *CSVWriter) Write(row []Field) error {
+	if err := w.write(row); err != nil {
+		return err
+	}
+	if w.digest != nil {
+		w.digest.Add(w.writtenRow(row))
+	}
+	return nil
+}
+
+// writtenRow returns row with the values which are written, and so read back,
+// when SetEscapeFormulas changes them.
+func (w *CSVWriter) writtenRow(row []Field) []Field {
+	written := row
+	for i, field := range row {
+		if val := w.escapeFormula(field.Val); val != field.Val {
+			if &written[0] == &row[0] {
+				written = slices.Clone(row)
+			}
+			written[i].Val = val
+		}
+	}
+	return written
+}
+
+// escapeFormula prefixes val by a quote if it's a formula to escape.
+func (w *CSVWriter) escapeFormula(val string) string {
+	if w.escapeFormulas && isFormula(val) {
+		return "'" + val
+	}
+	return val
+}
+
+func (w *CSVWriter) write(row []Field) error {
+	if len(row) == 1 && !row[0].IsNull && strings.TrimSpace(row[0].Val) == "" && len(w.quote) == 0 && !w.cfg.AllowEmptyLine {
+		return errors.New("cannot write a row of a single blank field without FieldEnclosedBy, the line would be skipped")
+	}
+	buf := append(w.buf[:0], w.cfg.LineStartingBy...)
+	for i, field := range row {
+		if i > 0 {
+			buf = append(buf, w.comma...)
+		}
+		var err error
+		buf, err = w.appendField(buf, field, len(row) == 1)
+		if err != nil {
+			return err
+		}
+	}
+	if w.cfg.TrimLastSep {
+		buf = append(buf, w.comma...)
+	}
+	buf = append(buf, w.newLine...)
+	w.buf = buf
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// WriteHeader writes the column names as a row.
+func (w *CSVWriter) WriteHeader(columns []string) error {
+	row := make([]Field, len(columns))
+	for i, column := range columns {
+		row[i].Val = column
+	}
+	return w.write(row)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *CSVWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *CSVWriter) appendField(buf []byte, field Field, onlyField bool) ([]byte, error) {
+	if field.IsNull {
+		return append(buf, w.null...), nil
+	}
+	val := w.escapeFormula(field.Val)
+	if !w.cfg.NotNull && slices.Contains(w.cfg.Null, val) && !w.canWriteNullText(val) {
+		return nil, fmt.Errorf("cannot write field %q, it would be read as NULL", field.Val)
+	}
+	if !(field.Quoted && len(w.quote) > 0) && !w.needsQuotes(val, onlyField) {
+		return append(buf, val...), nil
+	}
+	if len(w.quote) > 0 {
+		buf = append(buf, w.quote...)
+		for len(val) > 0 {
+			var n int
+			switch {
+			case strings.HasPrefix(val, w.quote):
+				buf = append(buf, w.quote...)
+				n = len(w.quote)
+			case len(w.escape) > 0 && val[0] == w.escape[0]:
+				buf = append(buf, w.escape[0])
+				n = 1
+			default:
+				n = 1
+			}
+			buf = append(buf, val[:n]...)
+			val = val[n:]
+		}
+		return append(buf, w.quote...), nil
+	}
+	if len(w.escape) == 0 {
+		return nil, fmt.Errorf("cannot write field %q without FieldEnclosedBy or FieldEscapedBy", field.Val)
+	}
+	for i := 0; i < len(val); i++ {
+		if strings.IndexByte(w.special, val[i]) != -1 {
+			buf = append(buf, w.escape[0])
+		}
+		buf = append(buf, val[i])
+	}
+	return buf, nil
+}
+
+// needsQuotes reports whether val must be quoted, or escaped when there is no
+// quote, to be read back unchanged.
+func (w *CSVWriter) needsQuotes(val string, onlyField bool) bool {
+	if !w.cfg.NotNull && len(w.quote) > 0 && slices.Contains(w.cfg.Null, val) {
+		return true
+	}
+	if val == "" {
+		// a row of a single empty field would be an empty line.
+		return onlyField && len(w.quote) > 0
+	}
+	if w.containsSpecial(val) || strings.Contains(val, w.comma) ||
+		(len(w.quote) > 0 && strings.Contains(val, w.quote)) {
+		return true
+	}
+	// the parser skips lines of whitespaces.
+	return strings.TrimSpace(val) != val
+}
+
+// containsSpecial reports whether val contains any byte of special. Unlike
+// strings.ContainsAny it works on bytes, not runes.
+func (w *CSVWriter) containsSpecial(val string) bool {
+	for i := 0; i < len(val); i++ {
+		if strings.IndexByte(w.special, val[i]) != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// canWriteNullText reports whether val, which is a Null value, can be written
+// so that it is read as text: either quoted, or `\N` written as `\\N`.
+func (w *CSVWriter) canWriteNullText(val string) bool {
+	if len(w.quote) > 0 && w.cfg.QuotedNullIsText {
+		return true
+	}
+	return len(w.escape) > 0 && val == w.escape+`N`
+}
+
+// isFormula reports whether a spreadsheet would evaluate val as a formula.
+func isFormula(val string) bool {
+	if len(val) == 0 || strings.IndexByte("=+-@", val[0]) == -1 {
+		return false
+	}
+	_, err := strconv.ParseFloat(val, 64)
+	return err != nil
+}
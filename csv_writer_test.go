@@ -0,0 +1,133 @@
+package mydump_test
+
+import (
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVWriterRoundTrip(t *testing.T) {
+	rows := [][]mydump.Field{
+		{newStringField("plain", false), newStringField("", false), newStringField(`\N`, true)},
+		{newStringField(`a,b`, false), newStringField(`say "hi"`, false), newStringField("multi\nline\r\n", false)},
+		{newStringField(`back\slash`, false), newStringField(` padded `, false), newStringField(`\N`, false)},
+		{newStringField("", false)},
+		{newStringField("NULL", false), newStringField("=1+2", false), newStringField("-1", false)},
+	}
+	configs := []mydump.CSVConfig{
+		{
+			FieldTerminatedBy: ",",
+			FieldEnclosedBy:   `"`,
+		},
+		{
+			FieldTerminatedBy: ",",
+			FieldEnclosedBy:   `"`,
+			FieldEscapedBy:    `\`,
+			LineTerminatedBy:  "\n",
+			Null:              []string{`\N`, "NULL"},
+			QuotedNullIsText:  true,
+		},
+		{
+			FieldTerminatedBy: "\t",
+			FieldEscapedBy:    `\`,
+			LineTerminatedBy:  "\n",
+			Null:              []string{`\N`},
+		},
+		{
+			FieldTerminatedBy: "||",
+			FieldEnclosedBy:   "'",
+			LineTerminatedBy:  "|\n",
+			LineStartingBy:    ">",
+			TrimLastSep:       true,
+		},
+	}
+	for i, cfg := range configs {
+		var out strings.Builder
+		writer := mydump.NewCSVWriter(&cfg, &out)
+		var written [][]mydump.Field
+		for _, row := range rows {
+			if len(row) == 1 && len(cfg.FieldEnclosedBy) == 0 {
+				require.Error(t, writer.Write(row))
+				continue
+			}
+			require.NoError(t, writer.Write(row))
+			written = append(written, row)
+		}
+		require.NoError(t, writer.Flush())
+
+		parser, err := mydump.NewCSVParser(&cfg, NewStringReader(out.String()), int64(mydump.ReadBlockSize), false, false)
+		require.NoError(t, err)
+		for _, row := range written {
+			actual, err := parser.Read()
+			require.NoError(t, err, "config %d: %s", i, out.String())
+			for j, field := range row {
+				if field.IsNull && len(cfg.Null) == 0 {
+					// without a NULL value, NULL is written as an empty field.
+					field = newStringField("", false)
+				}
+				require.Equal(t, field.IsNull, actual[j].IsNull, "config %d: %s", i, out.String())
+				if !field.IsNull {
+					require.Equal(t, field.Val, actual[j].Val, "config %d: %s", i, out.String())
+				}
+			}
+		}
+	}
+}
+
+func TestExcelDialect(t *testing.T) {
+	cfg := mydump.NewExcelConfig()
+
+	input := "\xEF\xBB\xBFsep=;\r\n" +
+		"id;code;note\r\n" +
+		"1;=\"00123\";\"a;b\"\r\n" +
+		"2;=A1+1;plain\r\n"
+	parser, err := mydump.NewCSVParser(cfg, NewStringReader(input), int64(mydump.ReadBlockSize), true, false)
+	require.NoError(t, err)
+
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("1", false),
+		newStringField("00123", false),
+		newStringField("a;b", false),
+	}, row)
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("2", false),
+		newStringField("=A1+1", false),
+		newStringField("plain", false),
+	}, row)
+
+	// without the hint line the configured separator is used.
+	parser, err = mydump.NewCSVParser(cfg, NewStringReader("a,=\"01\"\r\n"), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("a", false),
+		newStringField("01", false),
+	}, row)
+
+	// formulas are escaped by the Excel writer, numbers are not.
+	var out strings.Builder
+	writer := mydump.NewExcelWriter(&out)
+	require.NoError(t, writer.Write([]mydump.Field{
+		newStringField("=SUM(A1:A2)", false),
+		newStringField("+cmd", false),
+		newStringField("-1.5", false),
+		newStringField("@x", false),
+		newStringField("safe", false),
+	}))
+	require.NoError(t, writer.Flush())
+	require.Equal(t, "'=SUM(A1:A2),'+cmd,-1.5,'@x,safe\r\n", out.String())
+
+	// a CSVWriter only escapes them with SetEscapeFormulas.
+	out.Reset()
+	writer = mydump.NewCSVWriter(cfg, &out)
+	require.NoError(t, writer.Write([]mydump.Field{newStringField("=1", false)}))
+	require.NoError(t, writer.Flush())
+	require.Equal(t, "=1\r\n", out.String())
+}
@@ -0,0 +1,340 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	defaultDuplicateMemoryLimit int64 = 256 * 1024 * 1024
+	// keyEntryOverhead is roughly the memory used by a key in the map, besides
+	// the key itself.
+	keyEntryOverhead = 64
+	// spillFanout is the number of files the keys are spilled to, and
+	// maxSpillLevel how many times a file can be spilled again when its keys
+	// still don't fit in memory.
+	spillFanout   = 16
+	maxSpillLevel = 4
+)
+
+// RowLocation tells where a row is in the input, see RowMeta.
+type RowLocation struct {
+	RowID  int64
+	Offset int64
+	// Line is 0 if it's unknown.
+	Line int64
+}
+
+// Duplicate is a key found in two rows.
+type Duplicate struct {
+	Key    []Field
+	First  RowLocation
+	Second RowLocation
+}
+
+func (d Duplicate) String() string {
+	values := make([]string, len(d.Key))
+	for i, field := range d.Key {
+		if field.IsNull {
+			values[i] = "NULL"
+		} else {
+			values[i] = fmt.Sprintf("%q", field.Val)
+		}
+	}
+	return fmt.Sprintf("duplicate key (%s) in rows %d and %d", strings.Join(values, ", "), d.First.RowID, d.Second.RowID)
+}
+
+// DuplicateChecker finds the rows of a CSVParser with the same key, like the
+// conflicts a primary key or unique index would reject. The rows with a NULL
+// in the key are skipped, as a unique index allows them.
+//
+// The keys are kept in memory up to MemoryLimit. Beyond it, they are spilled
+// to spillFanout temporary files partitioned by hash, and the partitions are
+// checked one by one, so that files larger than the memory can be checked. A
+// partition still over the limit is spilled again, up to maxSpillLevel times,
+// after which Check fails with ErrDuplicateMemoryLimit rather than exceed the
+// limit.
+type DuplicateChecker struct {
+	// MemoryLimit is roughly the memory used by the keys before they are
+	// spilled, it defaults to 256 MiB.
+	MemoryLimit int64
+	// TempDir is where the keys are spilled, it defaults to os.TempDir().
+	TempDir string
+
+	columns []string
+}
+
+// ErrDuplicateMemoryLimit is returned by DuplicateChecker.Check when the keys
+// of a partition still exceed MemoryLimit after maxSpillLevel spills.
+var ErrDuplicateMemoryLimit = errors.New("the keys exceed the memory limit after spilling")
+
+// NewDuplicateChecker creates a DuplicateChecker for the key columns, given by
+// name as returned by Columns(), or "#i" for the i-th column. Without column,
+// the whole row is the key, and duplicate rows are reported, NULLs included.
+func NewDuplicateChecker(columns ...string) *DuplicateChecker {
+	return &DuplicateChecker{columns: columns}
+}
+
+// Check reads all the rows of parser and calls report for every duplicate, so
+// that the duplicates are never held in memory. A key found in n rows is
+// reported n-1 times, with its first row. The duplicates are reported in the
+// order of their second row while the keys fit in memory, then partition by
+// partition. If report returns an error, Check stops and returns it.
+func (c *DuplicateChecker) Check(parser *CSVParser, report func(Duplicate) error) error {
+	set := newKeySet(c, 0, report)
+	defer set.close()
+	var (
+		indexes []int
+		key     []byte
+	)
+	for {
+		row, meta, err := parser.ReadWithMeta()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if indexes == nil && len(c.columns) > 0 {
+			if indexes, err = resolveColumns(parser.Columns(), c.columns); err != nil {
+				return err
+			}
+		}
+		var ok bool
+		key, ok, err = c.appendKey(key[:0], row, indexes, meta.RowID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		loc := RowLocation{RowID: meta.RowID, Offset: meta.Start, Line: meta.Line}
+		if err = set.add(key, loc); err != nil {
+			return err
+		}
+	}
+	return set.finish()
+}
+
+// appendKey appends the key of row to buf, each field being a 0 byte if it's
+// NULL, else a 1 byte, the uvarint length of the value and the value. It
+// returns false if the key has a NULL.
+func (c *DuplicateChecker) appendKey(buf []byte, row []Field, indexes []int, rowID int64) ([]byte, bool, error) {
+	if len(c.columns) == 0 {
+		for _, field := range row {
+			buf = appendKeyField(buf, field)
+		}
+		return buf, true, nil
+	}
+	for i, index := range indexes {
+		if index >= len(row) {
+			return nil, false, fmt.Errorf("row %d has %d fields, key column %s is missing", rowID, len(row), c.columns[i])
+		}
+		if row[index].IsNull {
+			return buf, false, nil
+		}
+		buf = appendKeyField(buf, row[index])
+	}
+	return buf, true, nil
+}
+
+func appendKeyField(buf []byte, field Field) []byte {
+	if field.IsNull {
+		return append(buf, 0)
+	}
+	buf = append(buf, 1)
+	buf = binary.AppendUvarint(buf, uint64(len(field.Val)))
+	return append(buf, field.Val...)
+}
+
+func decodeKey(key string) []Field {
+	var fields []Field
+	for len(key) > 0 {
+		if key[0] == 0 {
+			fields = append(fields, Field{IsNull: true})
+			key = key[1:]
+			continue
+		}
+		n, size := binary.Uvarint([]byte(key[1:min(len(key), 1+binary.MaxVarintLen64)]))
+		key = key[1+size:]
+		fields = append(fields, Field{Val: key[:n]})
+		key = key[n:]
+	}
+	return fields
+}
+
+// keySet holds the first location of the keys, until they are spilled.
+type keySet struct {
+	c      *DuplicateChecker
+	level  int
+	keys   map[string]RowLocation
+	used   int64
+	spill  *keySpill
+	report func(Duplicate) error
+}
+
+func newKeySet(c *DuplicateChecker, level int, report func(Duplicate) error) *keySet {
+	return &keySet{c: c, level: level, keys: make(map[string]RowLocation), report: report}
+}
+
+// add adds a key, which must be added after the keys of the rows before it.
+func (s *keySet) add(key []byte, loc RowLocation) error {
+	if s.spill != nil {
+		return s.spill.add(key, loc)
+	}
+	if first, ok := s.keys[string(key)]; ok {
+		return s.report(Duplicate{Key: decodeKey(string(key)), First: first, Second: loc})
+	}
+	s.keys[string(key)] = loc
+	s.used += int64(len(key)) + keyEntryOverhead
+	limit := s.c.MemoryLimit
+	if limit <= 0 {
+		limit = defaultDuplicateMemoryLimit
+	}
+	if s.used <= limit {
+		return nil
+	}
+	if s.level >= maxSpillLevel {
+		return ErrDuplicateMemoryLimit
+	}
+	spill, err := newKeySpill(s.c.TempDir)
+	if err != nil {
+		return err
+	}
+	s.spill = spill
+	// the keys in memory are before the next ones, and they are all distinct,
+	// so writing them first keeps the first location of every key first.
+	for k, first := range s.keys {
+		if err = spill.add([]byte(k), first); err != nil {
+			return err
+		}
+	}
+	s.keys = nil
+	return nil
+}
+
+// finish checks the spilled keys, if any.
+func (s *keySet) finish() error {
+	if s.spill == nil {
+		return nil
+	}
+	defer s.close()
+	for _, w := range s.spill.writers {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	for _, f := range s.spill.files {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		sub := newKeySet(s.c, s.level+1, s.report)
+		err := readSpilledKeys(bufio.NewReader(f), sub.add)
+		if err == nil {
+			err = sub.finish()
+		}
+		sub.close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *keySet) close() {
+	if s.spill != nil {
+		s.spill.close()
+		s.spill = nil
+	}
+}
+
+// keySpill writes the keys and their location to spillFanout temporary files,
+// picked by the hash of the key.
+type keySpill struct {
+	seed    maphash.Seed
+	files   []*os.File
+	writers []*bufio.Writer
+	buf     []byte
+}
+
+func newKeySpill(dir string) (*keySpill, error) {
+	s := &keySpill{seed: maphash.MakeSeed()}
+	for i := 0; i < spillFanout; i++ {
+		f, err := os.CreateTemp(dir, "csv-keys-*")
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.files = append(s.files, f)
+		s.writers = append(s.writers, bufio.NewWriter(f))
+	}
+	return s, nil
+}
+
+func (s *keySpill) add(key []byte, loc RowLocation) error {
+	buf := binary.AppendUvarint(s.buf[:0], uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendVarint(buf, loc.RowID)
+	buf = binary.AppendVarint(buf, loc.Offset)
+	buf = binary.AppendVarint(buf, loc.Line)
+	s.buf = buf
+	_, err := s.writers[maphash.Bytes(s.seed, key)%spillFanout].Write(buf)
+	return err
+}
+
+func (s *keySpill) close() {
+	for _, f := range s.files {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+}
+
+// readSpilledKeys reads the keys written by keySpill.add, in order.
+func readSpilledKeys(r *bufio.Reader, add func([]byte, RowLocation) error) error {
+	var key []byte
+	for {
+		n, err := binary.ReadUvarint(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if uint64(cap(key)) < n {
+			key = make([]byte, n)
+		}
+		key = key[:n]
+		if _, err = io.ReadFull(r, key); err != nil {
+			return err
+		}
+		var values [3]int64
+		for i := range values {
+			if values[i], err = binary.ReadVarint(r); err != nil {
+				return err
+			}
+		}
+		if err = add(key, RowLocation{RowID: values[0], Offset: values[1], Line: values[2]}); err != nil {
+			return err
+		}
+	}
+}
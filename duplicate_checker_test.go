@@ -0,0 +1,119 @@
+package mydump_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicateChecker(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		Null:              []string{"NULL"},
+		HeaderSchemaMatch: true,
+	}
+	var b strings.Builder
+	b.WriteString("ID,Region,Note\n")
+	for i := 0; i < 500; i++ {
+		// every 7th row repeats the key of the row 100 rows before it.
+		id := i
+		if i%7 == 0 && i >= 100 {
+			id = i - 100
+		}
+		region := "eu"
+		if i%50 == 3 {
+			region = "NULL"
+		}
+		fmt.Fprintf(&b, "%d,%s,\"note\n%d\"\n", id, region, i)
+	}
+	input := []byte(b.String())
+
+	checkBytes := func(checker *mydump.DuplicateChecker, input []byte) ([]mydump.Duplicate, error) {
+		parser, err := mydump.NewCSVParserFromBytes(&cfg, input, true, false)
+		require.NoError(t, err)
+		var dups []mydump.Duplicate
+		err = checker.Check(parser, func(dup mydump.Duplicate) error {
+			dups = append(dups, dup)
+			return nil
+		})
+		// the spilled duplicates are reported partition by partition.
+		sort.Slice(dups, func(i, j int) bool {
+			return dups[i].Second.RowID < dups[j].Second.RowID
+		})
+		return dups, err
+	}
+	check := func(checker *mydump.DuplicateChecker) []mydump.Duplicate {
+		dups, err := checkBytes(checker, input)
+		require.NoError(t, err)
+		return dups
+	}
+
+	dups := check(mydump.NewDuplicateChecker("id", "region"))
+	var expected []int64
+	for i := 100; i < 500; i++ {
+		if i%7 == 0 && (i-100)%50 != 3 {
+			expected = append(expected, int64(i+1))
+		}
+	}
+	require.Len(t, dups, len(expected))
+	for i, dup := range dups {
+		require.Equal(t, expected[i], dup.Second.RowID)
+		require.Equal(t, dup.Second.RowID-100, dup.First.RowID)
+		require.Equal(t, 2*dup.First.RowID, dup.First.Line)
+	}
+	require.Equal(t, `duplicate key ("5", "eu") in rows 6 and 106`, dups[0].String())
+
+	// spilling every key gives the same duplicates, and removes its files.
+	dir := t.TempDir()
+	spilling := mydump.NewDuplicateChecker("id", "#1")
+	spilling.MemoryLimit = 1000
+	spilling.TempDir = dir
+	require.Equal(t, dups, check(spilling))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// the whole row is the key.
+	require.Empty(t, check(mydump.NewDuplicateChecker()))
+	dups, err = checkBytes(mydump.NewDuplicateChecker(), []byte("a,b\n1,NULL\n2,x\n1,NULL\n"))
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Duplicate{{
+		Key:    []mydump.Field{newStringField("1", false), newStringField("", true)},
+		First:  mydump.RowLocation{RowID: 1, Offset: 4, Line: 2},
+		Second: mydump.RowLocation{RowID: 3, Offset: 15, Line: 4},
+	}}, dups)
+
+	_, err = checkBytes(mydump.NewDuplicateChecker("b"), []byte("a,b\n1\n"))
+	require.EqualError(t, err, "row 1 has 1 fields, key column b is missing")
+	_, err = checkBytes(mydump.NewDuplicateChecker("c"), []byte("a,b\n1\n"))
+	require.EqualError(t, err, "column c is not in the columns [a b]")
+
+	// report can stop the check.
+	parser, err := mydump.NewCSVParserFromBytes(&cfg, input, true, false)
+	require.NoError(t, err)
+	stop := errors.New("stop")
+	reported := 0
+	err = mydump.NewDuplicateChecker("id").Check(parser, func(mydump.Duplicate) error {
+		reported++
+		return stop
+	})
+	require.Equal(t, stop, err)
+	require.Equal(t, 1, reported)
+
+	// the limit isn't exceeded when a partition can't be spilled again.
+	tooSmall := mydump.NewDuplicateChecker("id")
+	tooSmall.MemoryLimit = 1
+	tooSmall.TempDir = dir
+	_, err = checkBytes(tooSmall, input)
+	require.Equal(t, mydump.ErrDuplicateMemoryLimit, err)
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+// IndexAnyByteSet runs IndexAnyByte against a set made of chars.
+func IndexAnyByteSet(s []byte, chars []byte) int {
+	set := makeByteSet(chars)
+	return IndexAnyByte(s, &set)
+}
+
+// IndexAnyByteStopSet runs the search used by the parser, which picks the
+// vector or SWAR path for small sets.
+func IndexAnyByteStopSet(s []byte, chars []byte) int {
+	set := makeStopSet(chars)
+	return set.index(s)
+}
+
+// IndexAnyByteSWAR runs the SWAR path, even where there is a vector search.
+func IndexAnyByteSWAR(s []byte, chars []byte) int {
+	set := makeStopSet(chars)
+	if len(set.chars) == 0 {
+		return set.index(s)
+	}
+	return indexAnyByteSWAR(s, set.chars)
+}
+
+// SetTailWindowSize changes the first window read by Tail, and returns a
+// function restoring it.
+func SetTailWindowSize(size int64) func() {
+	prev := tailWindowSize
+	tailWindowSize = size
+	return func() { tailWindowSize = prev }
+}
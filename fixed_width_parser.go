@@ -0,0 +1,303 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// FixedWidthColumn is the position of a column inside a fixed-width record, in
+// bytes from the start of the record.
+type FixedWidthColumn struct {
+	Start  int
+	Length int
+	// PaddingSide overrides FixedWidthConfig.PaddingSide for this column.
+	PaddingSide PaddingSide
+}
+
+// PaddingSide tells which side of a value its padding is trimmed from.
+type PaddingSide uint8
+
+const (
+	// PaddingLeading trims the padding before right-aligned values, like
+	// zero-padded numbers.
+	PaddingLeading PaddingSide = 1 << iota
+	// PaddingTrailing trims the padding after left-aligned values, like text.
+	PaddingTrailing
+	// PaddingBoth trims both sides.
+	PaddingBoth = PaddingLeading | PaddingTrailing
+)
+
+type FixedWidthConfig struct {
+	// Columns gives the position of each column. Exactly one of Columns and
+	// Widths must be set.
+	Columns []FixedWidthColumn
+	// Widths gives the length of each column, the columns are adjacent and the
+	// first one starts at the beginning of the record.
+	Widths []int
+
+	// RecordLength is the length of every record in bytes. If it's set, records
+	// follow each other without any terminator, otherwise each record is a line
+	// ended by LineTerminatedBy.
+	RecordLength int
+	// LineTerminatedBy has the same meaning as in CSVConfig, it's ignored when
+	// RecordLength is set.
+	LineTerminatedBy string
+
+	// TrimPadding removes PaddingChar from the PaddingSide of every value, a
+	// zero PaddingChar means space. PaddingSide defaults to both sides for
+	// spaces, and must be set for other padding characters, since trimming a
+	// '0' from the wrong side changes the value.
+	TrimPadding bool
+	PaddingChar byte
+	PaddingSide PaddingSide
+
+	// these have the same meaning as in CSVConfig, Null values are matched after
+	// the padding is trimmed.
+	Null              []string
+	Header            bool
+	HeaderSchemaMatch bool
+	NotNull           bool
+}
+
+// FixedWidthParser parses files whose columns are found at fixed byte positions,
+// such as mainframe exports. It returns the same Field rows as CSVParser.
+type FixedWidthParser struct {
+	cfg     *FixedWidthConfig
+	specs   []FixedWidthColumn
+	padding string
+	// sides[i] is the PaddingSide of the i-th column.
+	sides []PaddingSide
+
+	// input is only used to read blocks and find terminators, so the two
+	// parsers share the buffering and Pos() semantics.
+	input *CSVParser
+
+	shouldParseHeader bool
+	columns           []string
+
+	lastRow  []Field
+	reuseRow bool
+}
+
+// NewFixedWidthParser creates a fixed-width parser.
+func NewFixedWidthParser(
+	cfg *FixedWidthConfig,
+	reader io.Reader,
+	blockBufSize int64,
+	shouldParseHeader bool,
+	reuseRow bool,
+) (*FixedWidthParser, error) {
+	specs := cfg.Columns
+	switch {
+	case len(cfg.Columns) > 0 && len(cfg.Widths) > 0:
+		return nil, errors.New("only one of Columns and Widths can be set")
+	case len(cfg.Widths) > 0:
+		specs = make([]FixedWidthColumn, 0, len(cfg.Widths))
+		start := 0
+		for _, width := range cfg.Widths {
+			specs = append(specs, FixedWidthColumn{Start: start, Length: width})
+			start += width
+		}
+	case len(cfg.Columns) == 0:
+		return nil, errors.New("one of Columns and Widths must be set")
+	}
+	for i, spec := range specs {
+		if spec.Start < 0 || spec.Length <= 0 {
+			return nil, fmt.Errorf("invalid column %d: start %d, length %d", i, spec.Start, spec.Length)
+		}
+		if cfg.RecordLength > 0 && spec.Start+spec.Length > cfg.RecordLength {
+			return nil, fmt.Errorf("column %d ends at %d, beyond the record length %d",
+				i, spec.Start+spec.Length, cfg.RecordLength)
+		}
+	}
+
+	var newLineStopSet []byte
+	if len(cfg.LineTerminatedBy) > 0 {
+		newLineStopSet = []byte{cfg.LineTerminatedBy[0]}
+	} else {
+		newLineStopSet = []byte{'\r', '\n'}
+	}
+	padding := " "
+	if cfg.PaddingChar != 0 {
+		padding = string([]byte{cfg.PaddingChar})
+	}
+	sides := make([]PaddingSide, len(specs))
+	for i, spec := range specs {
+		sides[i] = spec.PaddingSide
+		if sides[i] == 0 {
+			sides[i] = cfg.PaddingSide
+		}
+		switch {
+		case sides[i] > PaddingBoth:
+			return nil, fmt.Errorf("invalid padding side %d of column %d", sides[i], i)
+		case sides[i] == 0 && padding == " ":
+			sides[i] = PaddingBoth
+		case sides[i] == 0 && cfg.TrimPadding:
+			return nil, fmt.Errorf("the padding side of column %d must be set to trim %q", i, padding)
+		}
+	}
+	return &FixedWidthParser{
+		cfg:     cfg,
+		specs:   specs,
+		padding: padding,
+		sides:   sides,
+		input: &CSVParser{
+			reader:         reader,
+			blockBuf:       make([]byte, blockBufSize*BufferSizeScale),
+			remainBuf:      &bytes.Buffer{},
+			appendBuf:      &bytes.Buffer{},
+			newLine:        []byte(cfg.LineTerminatedBy),
+			newLineByteSet: makeStopSet(newLineStopSet),
+		},
+		shouldParseHeader: shouldParseHeader,
+		reuseRow:          reuseRow,
+	}, nil
+}
+
+func (parser *FixedWidthParser) Read() (row []Field, err error) {
+	if parser.reuseRow {
+		row, err = parser.readRow(parser.lastRow)
+		parser.lastRow = row
+	} else {
+		row, err = parser.readRow(nil)
+	}
+	return row, err
+}
+
+func (parser *FixedWidthParser) Pos() int64 {
+	return parser.input.pos
+}
+
+func (parser *FixedWidthParser) Columns() []string {
+	return parser.columns
+}
+
+func (parser *FixedWidthParser) SetColumns(columns []string) {
+	parser.columns = columns
+}
+
+func (parser *FixedWidthParser) readRow(row []Field) ([]Field, error) {
+	// skip the header first
+	if parser.shouldParseHeader {
+		err := parser.readColumns()
+		if err != nil {
+			return nil, err
+		}
+		parser.shouldParseHeader = false
+	}
+
+	record, err := parser.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	row = row[:0]
+	if cap(row) < len(parser.specs) {
+		row = make([]Field, len(parser.specs))
+	}
+	row = row[:len(parser.specs)]
+	for i := range parser.specs {
+		val := parser.columnValue(record, i)
+		row[i].Val = val
+		row[i].IsNull = !parser.cfg.NotNull && slices.Contains(parser.cfg.Null, val)
+	}
+	return row, nil
+}
+
+// columnValue returns the value of the i-th column, which is empty or cut short
+// if the record ends early.
+func (parser *FixedWidthParser) columnValue(record string, i int) string {
+	spec := parser.specs[i]
+	start := min(spec.Start, len(record))
+	end := min(spec.Start+spec.Length, len(record))
+	val := record[start:end]
+	if parser.cfg.TrimPadding {
+		if parser.sides[i]&PaddingLeading != 0 {
+			val = strings.TrimLeft(val, parser.padding)
+		}
+		if parser.sides[i]&PaddingTrailing != 0 {
+			val = strings.TrimRight(val, parser.padding)
+		}
+	}
+	return val
+}
+
+// readColumns reads the header record of this file.
+func (parser *FixedWidthParser) readColumns() error {
+	record, err := parser.readRecord()
+	if err != nil {
+		return err
+	}
+	if !parser.cfg.HeaderSchemaMatch {
+		return nil
+	}
+	parser.columns = make([]string, 0, len(parser.specs))
+	for i := range parser.specs {
+		colName := strings.TrimSpace(parser.columnValue(record, i))
+		parser.columns = append(parser.columns, strings.ToLower(colName))
+	}
+	return nil
+}
+
+// readRecord returns the next record without its terminator.
+func (parser *FixedWidthParser) readRecord() (string, error) {
+	if parser.cfg.RecordLength > 0 {
+		return parser.readFixedLengthRecord()
+	}
+	input := parser.input
+	for {
+		content, _, err := input.readUntilTerminator()
+		if err == nil {
+			if len(input.newLine) > 0 {
+				content = content[:len(content)-len(input.newLine)]
+			} else {
+				content = content[:len(content)-1]
+			}
+			// skip empty lines, like CSVParser does.
+			if len(content) == 0 {
+				continue
+			}
+		} else if err != io.EOF || len(content) == 0 {
+			return "", err
+		}
+		return string(content), nil
+	}
+}
+
+func (parser *FixedWidthParser) readFixedLengthRecord() (string, error) {
+	input := parser.input
+	n := parser.cfg.RecordLength
+	for len(input.buf) < n && !input.isLastChunk {
+		if err := input.readBlock(); err != nil {
+			return "", err
+		}
+	}
+	if len(input.buf) < n {
+		// tolerate a final line terminator after the last record.
+		if len(bytes.Trim(input.buf, "\r\n")) == 0 {
+			input.skipBytes(len(input.buf))
+			return "", io.EOF
+		}
+		return "", fmt.Errorf("incomplete record of %d bytes, expected %d bytes", len(input.buf), n)
+	}
+	record := string(input.buf[:n])
+	input.skipBytes(n)
+	return record, nil
+}
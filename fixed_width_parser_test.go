@@ -0,0 +1,114 @@
+package mydump_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedWidthLines(t *testing.T) {
+	cfg := mydump.FixedWidthConfig{
+		Widths:            []int{4, 10, 8},
+		TrimPadding:       true,
+		Null:              []string{"NULL"},
+		HeaderSchemaMatch: true,
+	}
+	input := "ID  NAME      AMOUNT  \r\n" +
+		"0001Alice     0012.50 \r\n" +
+		"\r\n" +
+		"0002Bob       NULL    \n" +
+		"0003Carol"
+	parser, err := mydump.NewFixedWidthParser(&cfg, strings.NewReader(input), 4, true, false)
+	require.NoError(t, err)
+
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "name", "amount"}, parser.Columns())
+	require.Equal(t, []mydump.Field{
+		newStringField("0001", false),
+		newStringField("Alice", false),
+		newStringField("0012.50", false),
+	}, row)
+	assertFixedWidthPos(t, parser, 47)
+
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("0002", false),
+		newStringField("Bob", false),
+		newStringField("NULL", true),
+	}, row)
+	assertFixedWidthPos(t, parser, 73)
+
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("0003", false),
+		newStringField("Carol", false),
+		newStringField("", false),
+	}, row)
+	assertFixedWidthPos(t, parser, 82)
+
+	_, err = parser.Read()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestFixedWidthRecordLength(t *testing.T) {
+	cfg := mydump.FixedWidthConfig{
+		Columns: []mydump.FixedWidthColumn{
+			{Start: 0, Length: 3, PaddingSide: mydump.PaddingTrailing},
+			{Start: 5, Length: 5},
+		},
+		RecordLength: 10,
+		TrimPadding:  true,
+		PaddingChar:  '0',
+		PaddingSide:  mydump.PaddingLeading,
+	}
+	parser, err := mydump.NewFixedWidthParser(&cfg, strings.NewReader("abc--00120xyz--12000\r\n"), 1, false, true)
+	require.NoError(t, err)
+
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("abc", false),
+		newStringField("120", false),
+	}, row)
+	assertFixedWidthPos(t, parser, 10)
+
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("xyz", false),
+		newStringField("12000", false),
+	}, row)
+	assertFixedWidthPos(t, parser, 20)
+
+	_, err = parser.Read()
+	require.Equal(t, io.EOF, err)
+
+	parser, err = mydump.NewFixedWidthParser(&cfg, strings.NewReader("abc--00120xyz"), 1, false, false)
+	require.NoError(t, err)
+	_, err = parser.Read()
+	require.NoError(t, err)
+	_, err = parser.Read()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "incomplete record")
+
+	cfg.Columns = append(cfg.Columns, mydump.FixedWidthColumn{Start: 8, Length: 3})
+	_, err = mydump.NewFixedWidthParser(&cfg, strings.NewReader(""), 1, false, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "beyond the record length")
+
+	// the side to trim '0' from must be explicit.
+	cfg.Columns = cfg.Columns[:2]
+	cfg.PaddingSide = 0
+	_, err = mydump.NewFixedWidthParser(&cfg, strings.NewReader(""), 1, false, false)
+	require.EqualError(t, err, `the padding side of column 1 must be set to trim "0"`)
+}
+
+func assertFixedWidthPos(t *testing.T, parser *mydump.FixedWidthParser, pos int64) {
+	require.Equal(t, pos, parser.Pos())
+}
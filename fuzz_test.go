@@ -0,0 +1,202 @@
+package mydump_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzConfig builds a config from fuzzed tokens, flags turns on the boolean
+// options one bit each.
+func fuzzConfig(sep, quote, escape, terminator, startingBy string, flags uint8) *mydump.CSVConfig {
+	return &mydump.CSVConfig{
+		FieldTerminatedBy: sep,
+		FieldEnclosedBy:   quote,
+		FieldEscapedBy:    escape,
+		LineTerminatedBy:  terminator,
+		LineStartingBy:    startingBy,
+		Null:              []string{`\N`, "NULL"},
+		TrimLastSep:       flags&1 != 0,
+		AllowEmptyLine:    flags&2 != 0,
+		QuotedNullIsText:  flags&4 != 0,
+		UnescapedQuote:    flags&8 != 0,
+		NotNull:           flags&16 != 0,
+		HeaderSchemaMatch: flags&32 != 0,
+	}
+}
+
+// tokensCollide reports whether the config is invalid, or a token starts with a
+// char that has a special meaning after the escape char, appears in a Null
+// value, or the quote overlaps itself. Such configs are ambiguous and can't
+// round-trip.
+func tokensCollide(cfg *mydump.CSVConfig) bool {
+	tokens := []string{cfg.FieldTerminatedBy, cfg.FieldEnclosedBy, cfg.FieldEscapedBy, cfg.LineTerminatedBy}
+	if len(cfg.LineTerminatedBy) == 0 {
+		tokens = append(tokens, "\r", "\n")
+	}
+	// a quote like "00" is ambiguous after a field ending with '0'.
+	quote := cfg.FieldEnclosedBy
+	for i := 1; i < len(quote); i++ {
+		if strings.HasSuffix(quote, quote[:i]) {
+			return true
+		}
+	}
+	// an unquoted NULL containing a token can't be read back.
+	for _, null := range cfg.Null {
+		for i, token := range tokens {
+			if i != 2 && len(token) > 0 && strings.IndexByte(null, token[0]) != -1 {
+				return true
+			}
+		}
+	}
+	for _, token := range tokens {
+		if len(cfg.FieldEscapedBy) > 0 && len(token) > 0 && strings.IndexByte("0bnrtZN", token[0]) != -1 {
+			return true
+		}
+	}
+	return cfg.Validate() != nil
+}
+
+func FuzzCSVParser(f *testing.F) {
+	f.Add([]byte("aaa,bbb,ccc\r\nzzz,yyy,xxx\r\n"), ",", `"`, "", "", "", uint8(0))
+	f.Add([]byte(`"a""b",c`+"\n"+`"multi`+"\n"+`line"`), ",", `"`, "", "\n", "", uint8(2))
+	f.Add([]byte(`"\"","\\","\?"`+"\n"+`\N,\\N`), ",", `"`, `\`, "\n", "", uint8(4))
+	f.Add([]byte(`3,"a string containing a " quote",102.20`), ",", `"`, `\`, "\n", "", uint8(8))
+	f.Add([]byte("xxx1,2\nignored\nabcxxx3,4"), ",", "", "", "\n", "xxx", uint8(0))
+	f.Add([]byte("a||b||\r\n|||c"), "||", "|+", "", "\r\n", "", uint8(1))
+	f.Add([]byte("🤔🌚a🌚🤔b"), "🤔", "🌚", "", "", "", uint8(32))
+	f.Fuzz(func(t *testing.T, input []byte, sep, quote, escape, terminator, startingBy string, flags uint8) {
+		if len(sep) == 0 || len(sep) > 4 || len(quote) > 4 || len(escape) > 1 || len(terminator) > 4 || len(startingBy) > 4 {
+			return
+		}
+		cfg := fuzzConfig(sep, quote, escape, terminator, startingBy, flags)
+		parser, err := mydump.NewCSVParser(cfg, bytes.NewReader(input), 1, flags&64 != 0, flags&128 != 0)
+		if err != nil {
+			return
+		}
+		var lastPos int64
+		// every row consumes at least one byte, so this bounds the loop.
+		for i := 0; i <= len(input)+1; i++ {
+			_, err = parser.Read()
+			pos := parser.Pos()
+			if err != nil {
+				require.LessOrEqual(t, pos, int64(len(input)))
+				return
+			}
+			require.LessOrEqual(t, pos, int64(len(input)))
+			require.Greater(t, pos, lastPos)
+			lastPos = pos
+		}
+		t.Fatal("the parser doesn't stop")
+	})
+}
+
+func FuzzCompareWithEncodingCSV(f *testing.F) {
+	f.Add("aaa,bbb,ccc\nzzz,yyy,xxx\n")
+	f.Add(`"aaa","b""bb","ccc"`)
+	f.Add("\"a\nb\",c\n\n\"\",d")
+	f.Add(`a,"b"c`)
+	f.Add(`a"b,c`)
+	f.Fuzz(func(t *testing.T, input string) {
+		// the parser treats a lone '\r' as a terminator, skips lines of
+		// whitespaces, and strips the BOM, unlike encoding/csv.
+		if strings.ContainsRune(input, '\r') || strings.HasPrefix(input, "\xEF\xBB\xBF") {
+			return
+		}
+		for _, line := range strings.Split(input, "\n") {
+			if len(line) > 0 && strings.TrimSpace(line) == "" {
+				return
+			}
+		}
+
+		reader := csv.NewReader(strings.NewReader(input))
+		reader.FieldsPerRecord = -1
+		cfg := mydump.CSVConfig{
+			FieldTerminatedBy: ",",
+			FieldEnclosedBy:   `"`,
+		}
+		parser, err := mydump.NewCSVParser(&cfg, strings.NewReader(input), 1, false, false)
+		require.NoError(t, err)
+		for {
+			expected, expectedErr := reader.Read()
+			row, err := parser.Read()
+			if expectedErr != nil {
+				if errors.Is(expectedErr, io.EOF) {
+					require.Equal(t, io.EOF, err)
+				} else {
+					require.Error(t, err)
+				}
+				return
+			}
+			require.NoError(t, err)
+			actual := make([]string, 0, len(row))
+			for _, field := range row {
+				actual = append(actual, field.Val)
+			}
+			require.Equal(t, expected, actual)
+		}
+	})
+}
+
+func FuzzWriterRoundTrip(f *testing.F) {
+	f.Add("a\x00b\x00c\x01d\x00\x00e", ",", `"`, "", "", uint8(0))
+	f.Add("say \"hi\"\x00multi\nline\x01\\N\x00NULL", ",", `"`, `\`, "\n", uint8(6))
+	f.Add("tab\there\x00back\\slash\x01\x00", "\t", "", `\`, "\n", uint8(2))
+	f.Fuzz(func(t *testing.T, data string, sep, quote, escape, terminator string, flags uint8) {
+		if len(sep) == 0 || len(sep) > 4 || len(quote) > 4 || len(escape) > 1 || len(terminator) > 4 {
+			return
+		}
+		cfg := fuzzConfig(sep, quote, escape, terminator, "", flags&(4|16))
+		if len(cfg.FieldEscapedBy) == 0 {
+			// without escape only the quoted NULL text can be told apart.
+			cfg.Null = []string{"NULL"}
+			cfg.QuotedNullIsText = true
+		}
+		if tokensCollide(cfg) {
+			return
+		}
+
+		// rows are separated by \x01 and fields by \x00, a field of "\x02"
+		// stands for NULL.
+		var rows [][]mydump.Field
+		for _, line := range strings.Split(data, "\x01") {
+			var row []mydump.Field
+			for _, val := range strings.Split(line, "\x00") {
+				row = append(row, newStringField(val, val == "\x02" && !cfg.NotNull))
+			}
+			rows = append(rows, row)
+		}
+
+		var out bytes.Buffer
+		writer := mydump.NewCSVWriter(cfg, &out)
+		var written [][]mydump.Field
+		for _, row := range rows {
+			if writer.Write(row) == nil {
+				written = append(written, row)
+			}
+		}
+		require.NoError(t, writer.Flush())
+
+		parser, err := mydump.NewCSVParser(cfg, &out, 1, false, false)
+		require.NoError(t, err)
+		for _, row := range written {
+			actual, err := parser.Read()
+			require.NoError(t, err)
+			require.Len(t, actual, len(row))
+			for i, field := range row {
+				require.Equal(t, field.IsNull, actual[i].IsNull)
+				if !field.IsNull {
+					require.Equal(t, field.Val, actual[i].Val)
+				}
+			}
+		}
+		_, err = parser.Read()
+		require.Equal(t, io.EOF, err)
+	})
+}
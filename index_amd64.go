@@ -0,0 +1,39 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build amd64 && !purego
+
+package mydump
+
+// indexAny16 returns the index of the first byte of s which is one of the four
+// bytes of chars, or -1. It compares 16 bytes at a time with SSE2, which every
+// amd64 CPU has, and len(s) must be a multiple of 16.
+//
+//go:noescape
+func indexAny16(s []byte, chars uint32) int
+
+// indexSmall searches b with indexAny16, and the tail of less than 16 bytes
+// with indexAnyByteSWAR.
+func (s *stopSet) indexSmall(b []byte) int {
+	n := len(b) &^ 15
+	if n > 0 {
+		if i := indexAny16(b[:n], s.packed); i >= 0 {
+			return i
+		}
+	}
+	if i := indexAnyByteSWAR(b[n:], s.chars); i >= 0 {
+		return n + i
+	}
+	return -1
+}
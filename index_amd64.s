@@ -0,0 +1,66 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func indexAny16(s []byte, chars uint32) int
+TEXT ·indexAny16(SB), NOSPLIT, $0-40
+	MOVQ s_base+0(FP), SI
+	MOVQ s_len+8(FP), BX
+	MOVL chars+24(FP), AX
+	MOVQ SI, DI
+
+	// X0..X3 hold each of the four chars repeated 16 times.
+	MOVQ      AX, X4
+	PUNPCKLBW X4, X4
+	PUNPCKLWL X4, X4
+	PSHUFD    $0x00, X4, X0
+	PSHUFD    $0x55, X4, X1
+	PSHUFD    $0xaa, X4, X2
+	PSHUFD    $0xff, X4, X3
+
+loop:
+	CMPQ     BX, $16
+	JB       notfound
+	MOVOU    (SI), X4
+	MOVO     X4, X5
+	PCMPEQB  X0, X5
+	MOVO     X4, X6
+	PCMPEQB  X1, X6
+	POR      X6, X5
+	MOVO     X4, X6
+	PCMPEQB  X2, X6
+	POR      X6, X5
+	PCMPEQB  X3, X4
+	POR      X4, X5
+	PMOVMSKB X5, DX
+	TESTL    DX, DX
+	JNZ      found
+	ADDQ     $16, SI
+	SUBQ     $16, BX
+	JMP      loop
+
+found:
+	// the lowest bit of the mask is the first matching byte.
+	BSFL DX, DX
+	SUBQ DI, SI
+	ADDQ DX, SI
+	MOVQ SI, ret+32(FP)
+	RET
+
+notfound:
+	MOVQ $-1, ret+32(FP)
+	RET
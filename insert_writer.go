@@ -0,0 +1,203 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+type InsertMode uint8
+
+const (
+	// InsertModeInsert writes `INSERT INTO` statements.
+	InsertModeInsert InsertMode = iota
+	// InsertModeIgnore writes `INSERT IGNORE INTO` statements.
+	InsertModeIgnore
+	// InsertModeReplace writes `REPLACE INTO` statements.
+	InsertModeReplace
+)
+
+type InsertConfig struct {
+	// Schema is optional, the table name is qualified by it when set.
+	Schema string
+	Table  string
+	Mode   InsertMode
+	// MaxRows is the max number of rows in a statement, 0 means no limit.
+	MaxRows int
+	// MaxBytes is the max size of a statement including the trailing ";\n",
+	// 0 means no limit. A row which is too large on its own is still written
+	// as a statement of one row.
+	MaxBytes int
+}
+
+// InsertWriter writes rows as batched multi-row INSERT statements. The values
+// are written as MySQL string literals and NULL fields as NULL.
+type InsertWriter struct {
+	w   io.Writer
+	cfg *InsertConfig
+
+	// prefix is the statement up to VALUES.
+	prefix []byte
+	stmt   bytes.Buffer
+	rowBuf []byte
+	rows   int
+}
+
+// NewInsertWriter creates an InsertWriter. If columns is empty the statements
+// have no column list.
+func NewInsertWriter(w io.Writer, cfg *InsertConfig, columns []string) *InsertWriter {
+	var prefix []byte
+	switch cfg.Mode {
+	case InsertModeIgnore:
+		prefix = append(prefix, "INSERT IGNORE INTO "...)
+	case InsertModeReplace:
+		prefix = append(prefix, "REPLACE INTO "...)
+	default:
+		prefix = append(prefix, "INSERT INTO "...)
+	}
+	if len(cfg.Schema) > 0 {
+		prefix = appendIdentifier(prefix, cfg.Schema)
+		prefix = append(prefix, '.')
+	}
+	prefix = appendIdentifier(prefix, cfg.Table)
+	if len(columns) > 0 {
+		prefix = append(prefix, " ("...)
+		for i, col := range columns {
+			if i > 0 {
+				prefix = append(prefix, ',')
+			}
+			prefix = appendIdentifier(prefix, col)
+		}
+		prefix = append(prefix, ')')
+	}
+	prefix = append(prefix, " VALUES\n"...)
+	return &InsertWriter{
+		w:      w,
+		cfg:    cfg,
+		prefix: prefix,
+	}
+}
+
+// Write adds a row to the current statement, the statement is written out
+// first if the row would exceed MaxRows or MaxBytes.
+func (w *InsertWriter) Write(row []Field) error {
+	w.rowBuf = append(w.rowBuf[:0], '(')
+	for i, field := range row {
+		if i > 0 {
+			w.rowBuf = append(w.rowBuf, ',')
+		}
+		if field.IsNull {
+			w.rowBuf = append(w.rowBuf, "NULL"...)
+		} else {
+			w.rowBuf = appendStringLiteral(w.rowBuf, field.Val)
+		}
+	}
+	w.rowBuf = append(w.rowBuf, ')')
+
+	if w.rows > 0 {
+		tooManyRows := w.cfg.MaxRows > 0 && w.rows >= w.cfg.MaxRows
+		// 4 is the size of ",\n" before the row plus ";\n" after it.
+		tooLarge := w.cfg.MaxBytes > 0 && w.stmt.Len()+len(w.rowBuf)+4 > w.cfg.MaxBytes
+		if tooManyRows || tooLarge {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if w.rows == 0 {
+		w.stmt.Write(w.prefix)
+	} else {
+		w.stmt.WriteString(",\n")
+	}
+	w.stmt.Write(w.rowBuf)
+	w.rows++
+	return nil
+}
+
+// Flush writes out the pending statement, if any.
+func (w *InsertWriter) Flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	w.stmt.WriteString(";\n")
+	_, err := w.w.Write(w.stmt.Bytes())
+	w.stmt.Reset()
+	w.rows = 0
+	return err
+}
+
+// WriteInsertStatements reads all rows of parser and writes them to w as
+// INSERT statements, using parser.Columns() as the column list. It returns the
+// number of rows written.
+func WriteInsertStatements(w io.Writer, parser *CSVParser, cfg *InsertConfig) (int64, error) {
+	var (
+		writer *InsertWriter
+		rows   int64
+	)
+	for {
+		row, err := parser.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		// the header is parsed by the first Read, so create the writer here.
+		if writer == nil {
+			writer = NewInsertWriter(w, cfg, parser.Columns())
+		}
+		if err = writer.Write(row); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	if writer == nil {
+		return 0, nil
+	}
+	return rows, writer.Flush()
+}
+
+// appendIdentifier appends name quoted by backticks.
+func appendIdentifier(buf []byte, name string) []byte {
+	buf = append(buf, '`')
+	buf = append(buf, strings.ReplaceAll(name, "`", "``")...)
+	return append(buf, '`')
+}
+
+// appendStringLiteral appends s as a single-quoted MySQL string literal, it
+// escapes the same characters as mysql_real_escape_string.
+func appendStringLiteral(buf []byte, s string) []byte {
+	buf = append(buf, '\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			buf = append(buf, '\\', '0')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case 0x1a:
+			buf = append(buf, '\\', 'Z')
+		case '\\', '\'', '"':
+			buf = append(buf, '\\', c)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '\'')
+}
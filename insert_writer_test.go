@@ -0,0 +1,62 @@
+package mydump_test
+
+import (
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteInsertStatements(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		FieldEscapedBy:    `\`,
+		Null:              []string{`\N`},
+		HeaderSchemaMatch: true,
+	}
+	input := "id,`name`,note\n" +
+		"1,O'Brien,\"say \"\"hi\"\"\"\n" +
+		"2,\\N,\"a\\\\b\"\n" +
+		"3,\"line\nbreak\",\"\\0\\Z\"\n"
+
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), int64(mydump.ReadBlockSize), true, false)
+	require.NoError(t, err)
+	var out strings.Builder
+	rows, err := mydump.WriteInsertStatements(&out, parser, &mydump.InsertConfig{
+		Schema:  "db",
+		Table:   "t",
+		MaxRows: 2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rows)
+	require.Equal(t, "INSERT INTO `db`.`t` (`id`,```name```,`note`) VALUES\n"+
+		`('1','O\'Brien','say \"hi\"'),`+"\n"+
+		`('2',NULL,'a\\b');`+"\n"+
+		"INSERT INTO `db`.`t` (`id`,```name```,`note`) VALUES\n"+
+		`('3','line\nbreak','\0\Z');`+"\n", out.String())
+}
+
+func TestInsertWriterMaxBytes(t *testing.T) {
+	var out strings.Builder
+	w := mydump.NewInsertWriter(&out, &mydump.InsertConfig{
+		Table:    "t",
+		Mode:     mydump.InsertModeReplace,
+		MaxBytes: 40,
+	}, nil)
+	for _, val := range []string{"a", "b", "a very long value that exceeds the limit", "c"} {
+		require.NoError(t, w.Write([]mydump.Field{newStringField(val, false)}))
+	}
+	require.NoError(t, w.Flush())
+	require.Equal(t, "REPLACE INTO `t` VALUES\n('a'),\n('b');\n"+
+		"REPLACE INTO `t` VALUES\n('a very long value that exceeds the limit');\n"+
+		"REPLACE INTO `t` VALUES\n('c');\n", out.String())
+
+	out.Reset()
+	w = mydump.NewInsertWriter(&out, &mydump.InsertConfig{Table: "t", Mode: mydump.InsertModeIgnore}, []string{"a"})
+	require.NoError(t, w.Write([]mydump.Field{newStringField("", true)}))
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Flush())
+	require.Equal(t, "INSERT IGNORE INTO `t` (`a`) VALUES\n(NULL);\n", out.String())
+}
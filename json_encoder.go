@@ -0,0 +1,252 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONConfig tells how a JSONLinesEncoder writes the values. Quoted fields,
+// see CSVConfig.KeepQuoting, are never coerced.
+type JSONConfig struct {
+	// CoerceNumbers writes values that are valid JSON numbers as numbers,
+	// so "12.5" becomes 12.5 while "007" stays a string.
+	CoerceNumbers bool
+	// CoerceBooleans writes "true" and "false", in any letter case, as
+	// booleans.
+	CoerceBooleans bool
+}
+
+// JSONLinesEncoder writes rows as JSON Lines (NDJSON). A row is written as an
+// object keyed by the column names if there are any, or as an array otherwise.
+// NULL fields are written as null. Rows are streamed through a fixed size
+// buffer, so memory use doesn't grow with the input.
+type JSONLinesEncoder struct {
+	w   *bufio.Writer
+	cfg *JSONConfig
+	// keys holds the encoded `"column":` prefixes, followed by the ones of
+	// the fields beyond the columns once they are seen.
+	keys [][]byte
+	// names is the set of the keys, so that the keys of the fields beyond
+	// the columns don't repeat one.
+	names map[string]struct{}
+	buf   []byte
+}
+
+// NewJSONLinesEncoder creates a JSONLinesEncoder. Fields beyond the given
+// columns are keyed by their position, like "column4", followed by as many
+// "_" as needed to differ from the columns.
+func NewJSONLinesEncoder(w io.Writer, cfg *JSONConfig, columns []string) *JSONLinesEncoder {
+	keys := make([][]byte, 0, len(columns))
+	names := make(map[string]struct{}, len(columns))
+	for _, col := range columns {
+		key := appendJSONString(nil, col)
+		keys = append(keys, append(key, ':'))
+		names[col] = struct{}{}
+	}
+	return &JSONLinesEncoder{
+		w:     bufio.NewWriter(w),
+		cfg:   cfg,
+		keys:  keys,
+		names: names,
+	}
+}
+
+// key returns the encoded key of the field i of an object.
+func (e *JSONLinesEncoder) key(i int) []byte {
+	for len(e.keys) <= i {
+		name := "column" + strconv.Itoa(len(e.keys)+1)
+		for {
+			if _, ok := e.names[name]; !ok {
+				break
+			}
+			name += "_"
+		}
+		e.names[name] = struct{}{}
+		e.keys = append(e.keys, append(appendJSONString(nil, name), ':'))
+	}
+	return e.keys[i]
+}
+
+// Write encodes a row as one line.
+func (e *JSONLinesEncoder) Write(row []Field) error {
+	buf := e.buf[:0]
+	isObject := len(e.keys) > 0
+	if isObject {
+		buf = append(buf, '{')
+	} else {
+		buf = append(buf, '[')
+	}
+	for i, field := range row {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if isObject {
+			buf = append(buf, e.key(i)...)
+		}
+		buf = e.appendValue(buf, field)
+	}
+	if isObject {
+		buf = append(buf, '}', '\n')
+	} else {
+		buf = append(buf, ']', '\n')
+	}
+	e.buf = buf
+	_, err := e.w.Write(buf)
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *JSONLinesEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *JSONLinesEncoder) appendValue(buf []byte, field Field) []byte {
+	switch {
+	case field.IsNull:
+		return append(buf, "null"...)
+	case field.Quoted:
+		return appendJSONString(buf, field.Val)
+	case e.cfg.CoerceNumbers && isJSONNumber(field.Val):
+		return append(buf, field.Val...)
+	case e.cfg.CoerceBooleans && strings.EqualFold(field.Val, "true"):
+		return append(buf, "true"...)
+	case e.cfg.CoerceBooleans && strings.EqualFold(field.Val, "false"):
+		return append(buf, "false"...)
+	default:
+		return appendJSONString(buf, field.Val)
+	}
+}
+
+// WriteJSONLines reads all rows of parser and writes them to w as JSON Lines,
+// keyed by parser.Columns(). It returns the number of rows written.
+func WriteJSONLines(w io.Writer, parser *CSVParser, cfg *JSONConfig) (int64, error) {
+	var (
+		encoder *JSONLinesEncoder
+		rows    int64
+	)
+	for {
+		row, err := parser.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		// the header is parsed by the first Read, so create the encoder here.
+		if encoder == nil {
+			encoder = NewJSONLinesEncoder(w, cfg, parser.Columns())
+		}
+		if err = encoder.Write(row); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	if encoder == nil {
+		return 0, nil
+	}
+	return rows, encoder.Flush()
+}
+
+// isJSONNumber reports whether s matches the JSON number grammar
+// -?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?
+func isJSONNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && s[i] >= '1' && s[i] <= '9':
+		i = skipDigits(s, i)
+	default:
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		j := skipDigits(s, i+1)
+		if j == i+1 {
+			return false
+		}
+		i = j
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		j := skipDigits(s, i)
+		if j == i {
+			return false
+		}
+		i = j
+	}
+	return i == len(s)
+}
+
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// appendJSONString appends s as a JSON string. Invalid UTF-8 is replaced by
+// U+FFFD like encoding/json does.
+func appendJSONString(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
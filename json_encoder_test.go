@@ -0,0 +1,76 @@
+package mydump_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSONLines(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		Null:              []string{"NULL"},
+		HeaderSchemaMatch: true,
+	}
+	input := "id,name,active,score\n" +
+		"1,\"quote \"\" and \\ slash\",TRUE,-1.5e3\n" +
+		"007,NULL,no,1.\n" +
+		"2,\"tab\tnew\nline \x01 \xff\",false,0,extra\n"
+
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), int64(mydump.ReadBlockSize), true, false)
+	require.NoError(t, err)
+	var out strings.Builder
+	rows, err := mydump.WriteJSONLines(&out, parser, &mydump.JSONConfig{
+		CoerceNumbers:  true,
+		CoerceBooleans: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rows)
+	require.Equal(t,
+		`{"id":1,"name":"quote \" and \\ slash","active":true,"score":-1.5e3}`+"\n"+
+			`{"id":"007","name":null,"active":"no","score":"1."}`+"\n"+
+			`{"id":2,"name":"tab\tnew\nline \u0001 �","active":false,"score":0,"column5":"extra"}`+"\n",
+		out.String())
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		require.True(t, json.Valid([]byte(line)), line)
+	}
+
+	// without a header, rows are arrays and no coercion happens by default.
+	parser, err = mydump.NewCSVParser(&cfg, NewStringReader("1,NULL,true\n"), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	out.Reset()
+	_, err = mydump.WriteJSONLines(&out, parser, &mydump.JSONConfig{})
+	require.NoError(t, err)
+	require.Equal(t, `["1",null,"true"]`+"\n", out.String())
+}
+
+func TestJSONLinesExtraFieldKeys(t *testing.T) {
+	// the key of the third field would repeat the header.
+	var out strings.Builder
+	encoder := mydump.NewJSONLinesEncoder(&out, &mydump.JSONConfig{}, []string{"column3", "column3_"})
+	require.NoError(t, encoder.Write([]mydump.Field{
+		newStringField("a", false),
+		newStringField("b", false),
+		newStringField("c", false),
+		newStringField("d", false),
+	}))
+	require.NoError(t, encoder.Write([]mydump.Field{newStringField("e", false)}))
+	require.NoError(t, encoder.Flush())
+	require.Equal(t,
+		`{"column3":"a","column3_":"b","column3__":"c","column4":"d"}`+"\n"+
+			`{"column3":"e"}`+"\n",
+		out.String())
+
+	out.Reset()
+	encoder = mydump.NewJSONLinesEncoder(&out, &mydump.JSONConfig{}, []string{"column2"})
+	require.NoError(t, encoder.Write([]mydump.Field{
+		newStringField("a", false),
+		newStringField("b", false),
+	}))
+	require.NoError(t, encoder.Flush())
+	require.Equal(t, `{"column2":"a","column2_":"b"}`+"\n", out.String())
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseLoadDataClause parses the field and line handling clauses of LOAD DATA
+// or SELECT ... INTO OUTFILE, like
+//
+//	FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' ESCAPED BY '\\'
+//	LINES STARTING BY '' TERMINATED BY '\r\n' IGNORE 1 LINES
+//
+// and returns the config and the number of lines to ignore, such as a header.
+// The omitted options have the MySQL defaults. Strings are MySQL string
+// literals, quoted by ' or ", or hex literals like X'01' and 0x01.
+// OPTIONALLY only matters when writing, so it's accepted and ignored.
+//
+// NULL is read as MySQL does: \N with an escape character, the word NULL
+// otherwise, and an unquoted NULL when fields are enclosed.
+// https://dev.mysql.com/doc/refman/8.0/en/load-data.html#load-data-field-line-handling
+func ParseLoadDataClause(clause string) (*CSVConfig, int, error) {
+	cfg := &CSVConfig{
+		FieldTerminatedBy: "\t",
+		FieldEscapedBy:    `\`,
+		LineTerminatedBy:  "\n",
+		QuotedNullIsText:  true,
+		UnescapedQuote:    true,
+	}
+	p := &clauseParser{input: clause}
+	ignoreLines := 0
+	var err error
+	for err == nil && !p.eof() {
+		switch word := p.keyword(); word {
+		case "FIELDS", "COLUMNS":
+			err = p.fieldsClause(cfg)
+		case "LINES":
+			err = p.linesClause(cfg)
+		case "IGNORE":
+			ignoreLines, err = p.ignoreClause()
+		default:
+			err = p.errorf("expected FIELDS, COLUMNS, LINES or IGNORE")
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(cfg.FieldEnclosedBy) > 1 {
+		return nil, 0, fmt.Errorf("ENCLOSED BY '%s' must be a single character", cfg.FieldEnclosedBy)
+	}
+	if len(cfg.FieldEscapedBy) > 0 {
+		cfg.Null = append(cfg.Null, cfg.FieldEscapedBy+`N`)
+	}
+	if len(cfg.FieldEscapedBy) == 0 || len(cfg.FieldEnclosedBy) > 0 {
+		cfg.Null = append(cfg.Null, "NULL")
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, 0, err
+	}
+	return cfg, ignoreLines, nil
+}
+
+// clauseParser reads the tokens of a LOAD DATA clause.
+type clauseParser struct {
+	input string
+	pos   int
+}
+
+func (p *clauseParser) errorf(format string, args ...any) error {
+	near := p.input[p.pos:]
+	if len(near) > 20 {
+		near = near[:20]
+	}
+	return fmt.Errorf("syntax error at offset %d near %q: %s", p.pos, near, fmt.Sprintf(format, args...))
+}
+
+func (p *clauseParser) skipSpaces() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+func (p *clauseParser) eof() bool {
+	p.skipSpaces()
+	return p.pos == len(p.input)
+}
+
+// peekKeyword returns the next word in upper case, without consuming it.
+func (p *clauseParser) peekKeyword() string {
+	p.skipSpaces()
+	end := p.pos
+	for end < len(p.input) && isWordByte(p.input[end]) {
+		end++
+	}
+	return strings.ToUpper(p.input[p.pos:end])
+}
+
+func (p *clauseParser) keyword() string {
+	word := p.peekKeyword()
+	p.pos += len(word)
+	return word
+}
+
+// expect consumes the given keywords or returns an error.
+func (p *clauseParser) expect(words ...string) error {
+	for _, word := range words {
+		if p.peekKeyword() != word {
+			return p.errorf("expected %s", word)
+		}
+		p.pos += len(word)
+	}
+	return nil
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// fieldsClause reads the options after FIELDS, in any order like MySQL does.
+func (p *clauseParser) fieldsClause(cfg *CSVConfig) error {
+	found := false
+	for {
+		var (
+			dst *string
+			err error
+		)
+		switch p.peekKeyword() {
+		case "TERMINATED":
+			err = p.expect("TERMINATED", "BY")
+			dst = &cfg.FieldTerminatedBy
+		case "OPTIONALLY":
+			err = p.expect("OPTIONALLY", "ENCLOSED", "BY")
+			dst = &cfg.FieldEnclosedBy
+		case "ENCLOSED":
+			err = p.expect("ENCLOSED", "BY")
+			dst = &cfg.FieldEnclosedBy
+		case "ESCAPED":
+			err = p.expect("ESCAPED", "BY")
+			dst = &cfg.FieldEscapedBy
+		default:
+			if !found {
+				return p.errorf("expected TERMINATED, ENCLOSED or ESCAPED BY")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if *dst, err = p.stringLiteral(); err != nil {
+			return err
+		}
+		found = true
+	}
+}
+
+// linesClause reads the options after LINES, in any order like MySQL does.
+func (p *clauseParser) linesClause(cfg *CSVConfig) error {
+	found := false
+	for {
+		var (
+			dst *string
+			err error
+		)
+		switch p.peekKeyword() {
+		case "STARTING":
+			err = p.expect("STARTING", "BY")
+			dst = &cfg.LineStartingBy
+		case "TERMINATED":
+			err = p.expect("TERMINATED", "BY")
+			dst = &cfg.LineTerminatedBy
+		default:
+			if !found {
+				return p.errorf("expected STARTING or TERMINATED BY")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if *dst, err = p.stringLiteral(); err != nil {
+			return err
+		}
+		found = true
+	}
+}
+
+// ignoreClause reads the `n LINES` or `n ROWS` after IGNORE.
+func (p *clauseParser) ignoreClause() (int, error) {
+	word := p.keyword()
+	n, err := strconv.Atoi(word)
+	if err != nil || n < 0 {
+		return 0, p.errorf("expected the number of lines to ignore")
+	}
+	if kw := p.keyword(); kw != "LINES" && kw != "ROWS" {
+		return 0, p.errorf("expected LINES or ROWS")
+	}
+	return n, nil
+}
+
+// stringLiteral reads a string quoted by ' or ", or a hex literal.
+func (p *clauseParser) stringLiteral() (string, error) {
+	p.skipSpaces()
+	rest := p.input[p.pos:]
+	switch {
+	case len(rest) >= 2 && (rest[0] == 'x' || rest[0] == 'X') && rest[1] == '\'':
+		end := strings.IndexByte(rest[2:], '\'')
+		if end < 0 {
+			return "", p.errorf("unterminated hex literal")
+		}
+		return p.hexLiteral(rest[2:2+end], 3+end)
+	case len(rest) >= 2 && rest[0] == '0' && rest[1] == 'x':
+		end := 2
+		for end < len(rest) && isWordByte(rest[end]) {
+			end++
+		}
+		digits := rest[2:end]
+		if len(digits)%2 == 1 {
+			// MySQL pads 0x1 to 0x01, unlike X'1' which is an error.
+			digits = "0" + digits
+		}
+		return p.hexLiteral(digits, end)
+	case len(rest) > 0 && (rest[0] == '\'' || rest[0] == '"'):
+		return p.quotedLiteral(rest)
+	default:
+		return "", p.errorf("expected a string")
+	}
+}
+
+// hexLiteral decodes the digits of a hex literal of size bytes.
+func (p *clauseParser) hexLiteral(digits string, size int) (string, error) {
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return "", p.errorf("invalid hex literal: %v", err)
+	}
+	p.pos += size
+	return string(b), nil
+}
+
+// quotedLiteral reads a string quoted by rest[0], with the escape sequences of
+// MySQL. \% and \_ keep their backslash since they are only meant for LIKE.
+func (p *clauseParser) quotedLiteral(rest string) (string, error) {
+	quote := rest[0]
+	var sb strings.Builder
+	for i := 1; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == quote:
+			if i+1 < len(rest) && rest[i+1] == quote {
+				sb.WriteByte(quote)
+				i++
+				continue
+			}
+			p.pos += i + 1
+			return sb.String(), nil
+		case c == '\\' && i+1 < len(rest):
+			i++
+			switch c = rest[i]; c {
+			case '0':
+				sb.WriteByte(0)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'Z':
+				sb.WriteByte(26)
+			case '%', '_':
+				sb.WriteByte('\\')
+				sb.WriteByte(c)
+			default:
+				sb.WriteByte(c)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
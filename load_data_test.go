@@ -0,0 +1,74 @@
+package mydump_test
+
+import (
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLoadDataClause(t *testing.T) {
+	cfg, ignore, err := mydump.ParseLoadDataClause("")
+	require.NoError(t, err)
+	require.Equal(t, 0, ignore)
+	require.Equal(t, &mydump.CSVConfig{
+		FieldTerminatedBy: "\t",
+		FieldEscapedBy:    `\`,
+		LineTerminatedBy:  "\n",
+		Null:              []string{`\N`},
+		QuotedNullIsText:  true,
+		UnescapedQuote:    true,
+	}, cfg)
+
+	cfg, ignore, err = mydump.ParseLoadDataClause(`fields terminated by ',' optionally enclosed by '"'
+		escaped by '\\' LINES STARTING BY 'x''y' TERMINATED BY '\r\n' IGNORE 1 LINES`)
+	require.NoError(t, err)
+	require.Equal(t, 1, ignore)
+	require.Equal(t, ",", cfg.FieldTerminatedBy)
+	require.Equal(t, `"`, cfg.FieldEnclosedBy)
+	require.Equal(t, `\`, cfg.FieldEscapedBy)
+	require.Equal(t, "x'y", cfg.LineStartingBy)
+	require.Equal(t, "\r\n", cfg.LineTerminatedBy)
+	require.Equal(t, []string{`\N`, "NULL"}, cfg.Null)
+
+	// options in any order, hex literals and an empty escape.
+	cfg, ignore, err = mydump.ParseLoadDataClause(`COLUMNS ESCAPED BY "" TERMINATED BY X'01'
+		LINES TERMINATED BY 0x0a IGNORE 2 ROWS`)
+	require.NoError(t, err)
+	require.Equal(t, 2, ignore)
+	require.Equal(t, "\x01", cfg.FieldTerminatedBy)
+	require.Equal(t, "", cfg.FieldEscapedBy)
+	require.Equal(t, "\n", cfg.LineTerminatedBy)
+	require.Equal(t, []string{"NULL"}, cfg.Null)
+
+	parser, err := mydump.NewCSVParser(cfg, NewStringReader("1\x01NULL\x01\\N\n"), 4, false, false)
+	require.NoError(t, err)
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("1", false),
+		newStringField("NULL", true),
+		newStringField(`\N`, false),
+	}, row)
+
+	errCases := []struct {
+		clause string
+		err    string
+	}{
+		{"FIELDS", "expected TERMINATED, ENCLOSED or ESCAPED BY"},
+		{"FIELDS TERMINATED ','", "expected BY"},
+		{"FIELDS TERMINATED BY ',", "unterminated string"},
+		{"FIELDS TERMINATED BY X'0'", "invalid hex literal"},
+		{"FIELDS ENCLOSED BY 'ab'", "ENCLOSED BY 'ab' must be a single character"},
+		{"FIELDS TERMINATED BY ''", "FieldTerminatedBy cannot be empty"},
+		{"FIELDS TERMINATED BY '\\t' ESCAPED BY '\\t'", "cannot be told apart"},
+		{"IGNORE x LINES", "expected the number of lines to ignore"},
+		{"IGNORE 1 COLUMNS", "expected LINES or ROWS"},
+		{"CHARACTER SET utf8mb4", "expected FIELDS, COLUMNS, LINES or IGNORE"},
+	}
+	for _, tc := range errCases {
+		_, _, err := mydump.ParseLoadDataClause(tc.clause)
+		require.Error(t, err, tc.clause)
+		require.Contains(t, err.Error(), tc.err, tc.clause)
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// shardRowsBufSize is the number of rows a shard read in the background can
+// get ahead of the caller.
+const shardRowsBufSize = 1024
+
+// MultiFileParser reads the shards of a table split over several CSV files,
+// like table.000.csv ... table.127.csv, as a single input. The rows are
+// returned in the order of the files.
+type MultiFileParser struct {
+	cfg               *CSVConfig
+	shardCfg          CSVConfig
+	files             []string
+	blockBufSize      int64
+	shouldParseHeader bool
+	concurrency       int
+
+	columns []string
+	// headerFile is the first shard which had a header, the others must have
+	// the same one.
+	headerFile string
+
+	current int
+	// shardPos is the offset in the current shard, and donePos the total size
+	// of the finished ones.
+	shardPos int64
+	donePos  int64
+	// err is the first error returned by Read, returned again by the next
+	// ones since the shard which failed can't go on.
+	err error
+
+	// used when the shards are read one by one.
+	file   *os.File
+	parser *CSVParser
+
+	// used when the shards are read concurrently.
+	shards []chan shardRow
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// shardRow is sent by the goroutine reading a shard in the background. The
+// first message carries the header, the last one has a non-nil err, io.EOF at
+// the end of the shard.
+type shardRow struct {
+	row     []Field
+	pos     int64
+	header  bool
+	columns []string
+	err     error
+}
+
+var _ RowReader = (*MultiFileParser)(nil)
+
+// NewMultiFileParser creates a parser reading files in order with the same
+// config. If shouldParseHeader is true, every shard starts with a header and
+// the headers must be the same. If concurrency is more than 1, up to that many
+// shards are parsed at the same time, the rows are still returned in order.
+// Close must be called when the parser isn't fully read.
+func NewMultiFileParser(
+	cfg *CSVConfig,
+	files []string,
+	blockBufSize int64,
+	shouldParseHeader bool,
+	concurrency int,
+) (*MultiFileParser, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no CSV file to read")
+	}
+	p := &MultiFileParser{
+		cfg:               cfg,
+		shardCfg:          *cfg,
+		files:             files,
+		blockBufSize:      blockBufSize,
+		shouldParseHeader: shouldParseHeader,
+		concurrency:       concurrency,
+	}
+	// the headers are always read to be compared.
+	p.shardCfg.HeaderSchemaMatch = true
+	if concurrency > 1 {
+		p.startShards()
+	}
+	return p, nil
+}
+
+// NewMultiFileParserFromGlob is NewMultiFileParser reading the files matching
+// pattern, in lexical order.
+func NewMultiFileParserFromGlob(
+	cfg *CSVConfig,
+	pattern string,
+	blockBufSize int64,
+	shouldParseHeader bool,
+	concurrency int,
+) (*MultiFileParser, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no CSV file matches %s", pattern)
+	}
+	return NewMultiFileParser(cfg, files, blockBufSize, shouldParseHeader, concurrency)
+}
+
+// Read returns the next row of the shards. Errors are prefixed by the name of
+// the shard and the offset in it, and once Read failed it returns the same
+// error.
+func (p *MultiFileParser) Read() ([]Field, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	var (
+		row []Field
+		err error
+	)
+	if p.shards != nil {
+		row, err = p.readConcurrent()
+	} else {
+		row, err = p.readSequential()
+	}
+	if err != nil && err != io.EOF {
+		p.err = err
+	}
+	return row, err
+}
+
+func (p *MultiFileParser) readSequential() ([]Field, error) {
+	for p.current < len(p.files) {
+		if p.parser == nil {
+			if err := p.openShard(); err != nil {
+				return nil, err
+			}
+		}
+		checkHeader := p.parser.shouldParseHeader
+		row, err := p.parser.Read()
+		p.shardPos = p.parser.Pos()
+		if checkHeader && (err == nil || err == io.EOF) {
+			if err2 := p.checkHeader(p.parser.Columns()); err2 != nil {
+				return nil, err2
+			}
+		}
+		if err == nil {
+			return row, nil
+		}
+		if err != io.EOF {
+			return nil, p.shardError(err)
+		}
+		if err = p.file.Close(); err != nil {
+			return nil, p.shardError(err)
+		}
+		p.file, p.parser = nil, nil
+		p.nextShard()
+	}
+	return nil, io.EOF
+}
+
+func (p *MultiFileParser) openShard() error {
+	file, err := os.Open(p.files[p.current])
+	if err != nil {
+		return p.shardError(err)
+	}
+	parser, err := NewCSVParser(&p.shardCfg, file, p.blockBufSize, p.shouldParseHeader, false)
+	if err != nil {
+		file.Close()
+		return p.shardError(err)
+	}
+	p.file, p.parser = file, parser
+	return nil
+}
+
+// nextShard moves to the next shard. The position in the last one is kept
+// for FilePos.
+func (p *MultiFileParser) nextShard() {
+	p.current++
+	if p.current < len(p.files) {
+		p.donePos += p.shardPos
+		p.shardPos = 0
+	}
+}
+
+// checkHeader compares the header of the current shard with the ones before.
+// An empty shard has no header to compare.
+func (p *MultiFileParser) checkHeader(columns []string) error {
+	if columns == nil {
+		return nil
+	}
+	if p.headerFile == "" {
+		p.columns = columns
+		p.headerFile = p.files[p.current]
+		return nil
+	}
+	if !slices.Equal(columns, p.columns) {
+		return fmt.Errorf("%s: header %v doesn't match the header %v of %s",
+			p.files[p.current], columns, p.columns, p.headerFile)
+	}
+	return nil
+}
+
+func (p *MultiFileParser) shardError(err error) error {
+	return fmt.Errorf("%s at offset %d: %w", p.files[p.current], p.shardPos, err)
+}
+
+// startShards starts a goroutine which starts reading the shards in order,
+// without reading more than p.concurrency at the same time. Since a shard is
+// only started after all the shards before it, the one read by Read always
+// makes progress.
+func (p *MultiFileParser) startShards() {
+	p.shards = make([]chan shardRow, len(p.files))
+	for i := range p.shards {
+		p.shards[i] = make(chan shardRow, shardRowsBufSize)
+	}
+	p.done = make(chan struct{})
+	sem := make(chan struct{}, p.concurrency)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for i := range p.files {
+			select {
+			case sem <- struct{}{}:
+			case <-p.done:
+				return
+			}
+			p.wg.Add(1)
+			go func(i int) {
+				defer p.wg.Done()
+				defer func() { <-sem }()
+				p.readShard(p.files[i], p.shards[i])
+			}(i)
+		}
+	}()
+}
+
+// readShard sends the rows of a shard to ch, it stops early if the parser is
+// closed.
+func (p *MultiFileParser) readShard(name string, ch chan<- shardRow) {
+	send := func(msg shardRow) bool {
+		select {
+		case ch <- msg:
+			return true
+		case <-p.done:
+			return false
+		}
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		send(shardRow{err: err})
+		return
+	}
+	defer file.Close()
+	parser, err := NewCSVParser(&p.shardCfg, file, p.blockBufSize, p.shouldParseHeader, false)
+	if err != nil {
+		send(shardRow{err: err})
+		return
+	}
+	for {
+		checkHeader := parser.shouldParseHeader
+		row, err := parser.Read()
+		if checkHeader && (err == nil || err == io.EOF) {
+			if !send(shardRow{header: true, columns: parser.Columns()}) {
+				return
+			}
+		}
+		if !send(shardRow{row: row, pos: parser.Pos(), err: err}) || err != nil {
+			return
+		}
+	}
+}
+
+func (p *MultiFileParser) readConcurrent() ([]Field, error) {
+	for p.current < len(p.files) {
+		msg := <-p.shards[p.current]
+		if msg.header {
+			if err := p.checkHeader(msg.columns); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		p.shardPos = msg.pos
+		if msg.err == nil {
+			return msg.row, nil
+		}
+		if msg.err != io.EOF {
+			return nil, p.shardError(msg.err)
+		}
+		p.nextShard()
+	}
+	return nil, io.EOF
+}
+
+// Pos returns the total size of the input parsed, over all the shards.
+func (p *MultiFileParser) Pos() int64 {
+	return p.donePos + p.shardPos
+}
+
+// FilePos returns the name of the shard being read and the position in it.
+func (p *MultiFileParser) FilePos() (string, int64) {
+	return p.files[min(p.current, len(p.files)-1)], p.shardPos
+}
+
+// Columns returns the column names of the header, when HeaderSchemaMatch is
+// set like for CSVParser.
+func (p *MultiFileParser) Columns() []string {
+	if !p.cfg.HeaderSchemaMatch {
+		return nil
+	}
+	return p.columns
+}
+
+// Close stops reading the shards and closes the files.
+func (p *MultiFileParser) Close() error {
+	if p.shards != nil {
+		select {
+		case <-p.done:
+		default:
+			close(p.done)
+		}
+		p.wg.Wait()
+		return nil
+	}
+	if p.file != nil {
+		err := p.file.Close()
+		p.file, p.parser = nil, nil
+		return err
+	}
+	return nil
+}
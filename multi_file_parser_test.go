@@ -0,0 +1,99 @@
+package mydump_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func writeShards(t *testing.T, shards ...string) string {
+	dir := t.TempDir()
+	for i, content := range shards {
+		name := filepath.Join(dir, fmt.Sprintf("table.%03d.csv", i))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	}
+	return filepath.Join(dir, "table.*.csv")
+}
+
+func TestMultiFileParser(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", HeaderSchemaMatch: true}
+	var shards []string
+	var expected [][]mydump.Field
+	for i := 0; i < 20; i++ {
+		content := "ID,name\n"
+		for j := 0; j < i*100; j++ {
+			id := fmt.Sprintf("%d-%d", i, j)
+			content += id + ",x\n"
+			expected = append(expected, []mydump.Field{newStringField(id, false), newStringField("x", false)})
+		}
+		shards = append(shards, content)
+	}
+	pattern := writeShards(t, shards...)
+	for _, concurrency := range []int{1, 4} {
+		parser, err := mydump.NewMultiFileParserFromGlob(&cfg, pattern, 64, true, concurrency)
+		require.NoError(t, err)
+		rows, columns := loadAll(t, parser)
+		require.Equal(t, expected, rows)
+		require.Equal(t, []string{"id", "name"}, columns)
+
+		var total int64
+		for _, content := range shards {
+			total += int64(len(content))
+		}
+		require.Equal(t, total, parser.Pos())
+		name, pos := parser.FilePos()
+		require.Equal(t, "table.019.csv", filepath.Base(name))
+		require.Equal(t, int64(len(shards[19])), pos)
+		require.NoError(t, parser.Close())
+	}
+}
+
+func TestMultiFileParserErrors(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`}
+	for _, concurrency := range []int{1, 3} {
+		pattern := writeShards(t, "a,b\n1,2\n", "a,b\n3,4\n", "a,c\n5,6\n")
+		parser, err := mydump.NewMultiFileParserFromGlob(&cfg, pattern, 64, true, concurrency)
+		require.NoError(t, err)
+		_, err = parser.Read()
+		require.NoError(t, err)
+		_, err = parser.Read()
+		require.NoError(t, err)
+		_, err = parser.Read()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "table.002.csv: header [a c] doesn't match")
+		// the rows of the rejected shard are never returned.
+		_, err2 := parser.Read()
+		require.Equal(t, err, err2)
+		require.NoError(t, parser.Close())
+
+		pattern = writeShards(t, "1,2\n", "3,\"4\n")
+		parser, err = mydump.NewMultiFileParserFromGlob(&cfg, pattern, 64, false, concurrency)
+		require.NoError(t, err)
+		_, err = parser.Read()
+		require.NoError(t, err)
+		_, err = parser.Read()
+		require.Contains(t, err.Error(), "unterminated quoted field")
+		require.Contains(t, err.Error(), "table.001.csv at offset")
+		// the failed shard isn't read again, which would block when concurrent.
+		_, err2 = parser.Read()
+		require.Equal(t, err, err2)
+		_, err2 = parser.Read()
+		require.Equal(t, err, err2)
+		require.NoError(t, parser.Close())
+	}
+
+	// closing early stops the shards read in the background.
+	pattern := writeShards(t, "1\n2\n3\n", "4\n", "5\n", "6\n")
+	parser, err := mydump.NewMultiFileParserFromGlob(&cfg, pattern, 64, false, 2)
+	require.NoError(t, err)
+	_, err = parser.Read()
+	require.NoError(t, err)
+	require.NoError(t, parser.Close())
+
+	_, err = mydump.NewMultiFileParserFromGlob(&cfg, filepath.Join(t.TempDir(), "*.csv"), 64, false, 1)
+	require.Error(t, err)
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type NDJSONConfig struct {
+	// StringifyNested converts a nested object or array into the value of a
+	// field. By default the compacted JSON text is used.
+	StringifyNested func(raw json.RawMessage) (string, error)
+	// IgnoreUnknownKeys drops the keys which are not in Columns(), by default
+	// such keys are an error.
+	IgnoreUnknownKeys bool
+}
+
+// NDJSONParser reads rows from JSON Lines (NDJSON) input. Every value in the
+// input is a row: the keys of an object are mapped to columns and an array is
+// read by position. Unless SetColumns is called, the columns are the keys of
+// the first object in their order of appearance, and keys missing from an
+// object are NULL. JSON null is NULL too, and scalars are kept in their JSON
+// text form, except strings which are unquoted.
+type NDJSONParser struct {
+	cfg     *NDJSONConfig
+	decoder *json.Decoder
+
+	columns     []string
+	columnIndex map[string]int
+
+	nested bytes.Buffer
+
+	lastRow  []Field
+	reuseRow bool
+}
+
+var _ RowReader = (*NDJSONParser)(nil)
+
+// NewNDJSONParser creates an NDJSON parser.
+func NewNDJSONParser(cfg *NDJSONConfig, reader io.Reader, reuseRow bool) *NDJSONParser {
+	return &NDJSONParser{
+		cfg:      cfg,
+		decoder:  json.NewDecoder(reader),
+		reuseRow: reuseRow,
+	}
+}
+
+func (parser *NDJSONParser) Read() (row []Field, err error) {
+	if parser.reuseRow {
+		row, err = parser.readRow(parser.lastRow)
+		parser.lastRow = row
+	} else {
+		row, err = parser.readRow(nil)
+	}
+	return row, err
+}
+
+// Pos returns the offset of the input after the last parsed value.
+func (parser *NDJSONParser) Pos() int64 {
+	return parser.decoder.InputOffset()
+}
+
+func (parser *NDJSONParser) Columns() []string {
+	return parser.columns
+}
+
+func (parser *NDJSONParser) SetColumns(columns []string) {
+	parser.columns = columns
+	parser.columnIndex = make(map[string]int, len(columns))
+	for i, col := range columns {
+		parser.columnIndex[col] = i
+	}
+}
+
+func (parser *NDJSONParser) readRow(row []Field) ([]Field, error) {
+	tok, err := parser.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		return parser.readObject(row[:0])
+	case json.Delim('['):
+		return parser.readArray(row[:0])
+	default:
+		return nil, fmt.Errorf("syntax error: expected an object or an array at offset %d, got %v", parser.Pos(), tok)
+	}
+}
+
+func (parser *NDJSONParser) readObject(row []Field) ([]Field, error) {
+	if parser.columnIndex == nil {
+		parser.columnIndex = make(map[string]int)
+	}
+	// the first object defines the columns if they are not set.
+	defineColumns := len(parser.columns) == 0
+	for range parser.columns {
+		row = append(row, Field{IsNull: true})
+	}
+	for parser.decoder.More() {
+		tok, err := parser.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		i, ok := parser.columnIndex[key]
+		if !ok {
+			if !defineColumns {
+				if !parser.cfg.IgnoreUnknownKeys {
+					return nil, fmt.Errorf("unknown key %q at offset %d", key, parser.Pos())
+				}
+				if err = parser.decoder.Decode(&json.RawMessage{}); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			i = len(parser.columns)
+			parser.columns = append(parser.columns, key)
+			parser.columnIndex[key] = i
+			row = append(row, Field{})
+		}
+		if row[i], err = parser.readValue(); err != nil {
+			return nil, err
+		}
+	}
+	// consume the closing '}'
+	if _, err := parser.decoder.Token(); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func (parser *NDJSONParser) readArray(row []Field) ([]Field, error) {
+	for parser.decoder.More() {
+		field, err := parser.readValue()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, field)
+	}
+	// consume the closing ']'
+	if _, err := parser.decoder.Token(); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func (parser *NDJSONParser) readValue() (Field, error) {
+	var raw json.RawMessage
+	if err := parser.decoder.Decode(&raw); err != nil {
+		return Field{}, err
+	}
+	switch raw[0] {
+	case 'n':
+		return Field{IsNull: true}, nil
+	case '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return Field{Val: s}, err
+	case '{', '[':
+		if parser.cfg.StringifyNested != nil {
+			s, err := parser.cfg.StringifyNested(raw)
+			return Field{Val: s}, err
+		}
+		parser.nested.Reset()
+		err := json.Compact(&parser.nested, raw)
+		return Field{Val: parser.nested.String()}, err
+	default:
+		return Field{Val: string(raw)}, nil
+	}
+}
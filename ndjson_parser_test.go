@@ -0,0 +1,113 @@
+package mydump_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+// loadAll is written against RowReader, like a format-agnostic loader.
+func loadAll(t *testing.T, reader mydump.RowReader) ([][]mydump.Field, []string) {
+	var rows [][]mydump.Field
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, reader.Columns()
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestNDJSONParserMatchesCSV(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		Null:              []string{"NULL"},
+		HeaderSchemaMatch: true,
+	}
+	csvParser, err := mydump.NewCSVParser(&cfg, NewStringReader(
+		"id,name,tags\n1,\"a \"\"b\"\"\",NULL\n2,NULL,\"[1,2]\"\n"),
+		int64(mydump.ReadBlockSize), true, false)
+	require.NoError(t, err)
+	ndjsonParser := mydump.NewNDJSONParser(&mydump.NDJSONConfig{}, strings.NewReader(
+		`{"id": 1, "name": "a \"b\"", "tags": null}`+"\n"+
+			`{"tags": [1, 2], "id": 2}`+"\n"), false)
+
+	csvRows, csvColumns := loadAll(t, csvParser)
+	ndjsonRows, ndjsonColumns := loadAll(t, ndjsonParser)
+	require.Equal(t, csvColumns, ndjsonColumns)
+	// the CSV parser keeps the text of NULL fields.
+	for _, row := range csvRows {
+		for i := range row {
+			if row[i].IsNull {
+				row[i].Val = ""
+			}
+		}
+	}
+	require.Equal(t, csvRows, ndjsonRows)
+}
+
+func TestNDJSONParser(t *testing.T) {
+	input := `{"a": true, "b": {"x": [1, "y"]}, "c": 1.50}` + "\n" +
+		"\n" +
+		`["x", null, {"k": 1}]` + "\n" +
+		`{"c": "only c"}`
+	parser := mydump.NewNDJSONParser(&mydump.NDJSONConfig{
+		StringifyNested: func(raw json.RawMessage) (string, error) {
+			return "nested:" + string(raw), nil
+		},
+	}, strings.NewReader(input), true)
+
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, parser.Columns())
+	require.Equal(t, []mydump.Field{
+		newStringField("true", false),
+		newStringField(`nested:{"x": [1, "y"]}`, false),
+		newStringField("1.50", false),
+	}, row)
+	require.Equal(t, int64(44), parser.Pos())
+
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("x", false),
+		newStringField("", true),
+		newStringField(`nested:{"k": 1}`, false),
+	}, row)
+	require.Equal(t, int64(67), parser.Pos())
+
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("", true),
+		newStringField("", true),
+		newStringField("only c", false),
+	}, row)
+
+	_, err = parser.Read()
+	require.Equal(t, io.EOF, err)
+
+	parser = mydump.NewNDJSONParser(&mydump.NDJSONConfig{}, strings.NewReader(`{"a":1} {"b":2} 3`), false)
+	_, err = parser.Read()
+	require.NoError(t, err)
+	_, err = parser.Read()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unknown key "b"`)
+
+	parser = mydump.NewNDJSONParser(&mydump.NDJSONConfig{IgnoreUnknownKeys: true}, strings.NewReader(`{"a":1} {"b":{"c":2}} 3`), false)
+	_, err = parser.Read()
+	require.NoError(t, err)
+	row, err = parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{newStringField("", true)}, row)
+	_, err = parser.Read()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expected an object or an array")
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+// The presets below return a new config every time, so callers can adjust it.
+// The files each of them accepts are in testdata/presets.
+
+// NewRFC4180Config returns the config of RFC 4180 CSV: comma separated,
+// quoted by '"' with doubled quotes inside, and no NULL. Rows may end by
+// CRLF, as the RFC says, or by a lone LF or CR.
+func NewRFC4180Config() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		LineEnding:        LineEndingAny,
+	}
+}
+
+// NewMySQLConfig returns the defaults of MySQL SELECT ... INTO OUTFILE and
+// LOAD DATA: tab separated, not quoted, escaped by '\' and NULL written as \N.
+func NewMySQLConfig() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: "\t",
+		FieldEscapedBy:    `\`,
+		LineTerminatedBy:  "\n",
+		Null:              []string{`\N`},
+	}
+}
+
+// NewPostgresConfig returns the config of COPY ... WITH (FORMAT csv): comma
+// separated, quoted by '"' with doubled quotes inside, and NULL written as an
+// unquoted empty field, while "" is an empty string.
+func NewPostgresConfig() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		LineEnding:        LineEndingAny,
+		Null:              []string{""},
+		QuotedNullIsText:  true,
+	}
+}
+
+// NewTSVConfig returns the config of IANA text/tab-separated-values: tab
+// separated, and neither quoted nor escaped since values can't contain tabs
+// or newlines.
+func NewTSVConfig() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: "\t",
+		LineEnding:        LineEndingAny,
+	}
+}
+
+// NewHiveConfig returns the defaults of the Hive text format: separated by
+// \x01, not quoted nor escaped, and NULL written as \N.
+func NewHiveConfig() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: "\x01",
+		LineTerminatedBy:  "\n",
+		Null:              []string{`\N`},
+	}
+}
+
+// NewSnowflakeConfig returns the defaults of Snowflake COPY INTO <location>
+// with TYPE = CSV: comma separated, not quoted, special characters escaped by
+// '\' and NULL written as \N. Set FieldEnclosedBy for files unloaded with
+// FIELD_OPTIONALLY_ENCLOSED_BY.
+func NewSnowflakeConfig() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEscapedBy:    `\`,
+		LineTerminatedBy:  "\n",
+		Null:              []string{`\N`},
+	}
+}
+
+// NewBigQueryConfig returns the defaults of BigQuery EXPORT DATA and extract
+// jobs in CSV: comma separated with a header, quoted by '"' with doubled
+// quotes inside, and NULL written as an unquoted empty field.
+func NewBigQueryConfig() *CSVConfig {
+	return &CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		LineEnding:        LineEndingAny,
+		Null:              []string{""},
+		QuotedNullIsText:  true,
+		Header:            true,
+		HeaderSchemaMatch: true,
+	}
+}
@@ -0,0 +1,47 @@
+package mydump_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestPresets parses testdata/presets/<name>.csv with each preset and compares
+// the rows, encoded as JSON Lines, with <name>.golden.
+func TestPresets(t *testing.T) {
+	presets := map[string]*mydump.CSVConfig{
+		"rfc4180":   mydump.NewRFC4180Config(),
+		"mysql":     mydump.NewMySQLConfig(),
+		"postgres":  mydump.NewPostgresConfig(),
+		"tsv":       mydump.NewTSVConfig(),
+		"hive":      mydump.NewHiveConfig(),
+		"snowflake": mydump.NewSnowflakeConfig(),
+		"bigquery":  mydump.NewBigQueryConfig(),
+	}
+	for name, cfg := range presets {
+		require.NoError(t, cfg.Validate(), name)
+		input, err := os.ReadFile(filepath.Join("testdata", "presets", name+".csv"))
+		require.NoError(t, err)
+		parser, err := mydump.NewCSVParserFromBytes(cfg, input, cfg.Header, false)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		_, err = mydump.WriteJSONLines(&out, parser, &mydump.JSONConfig{})
+		require.NoError(t, err, name)
+
+		golden := filepath.Join("testdata", "presets", name+".golden")
+		if *updateGolden {
+			require.NoError(t, os.WriteFile(golden, out.Bytes(), 0o644))
+			continue
+		}
+		expected, err := os.ReadFile(golden)
+		require.NoError(t, err)
+		require.Equal(t, string(expected), out.String(), name)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"sync"
+	"time"
+)
+
+// ParserStats is a snapshot of the progress of a CSVParser.
+type ParserStats struct {
+	// BytesConsumed is the Pos() after the last Read.
+	BytesConsumed int64
+	// RowsParsed is the number of rows returned by Read.
+	RowsParsed int64
+	// BlocksRead is the number of blocks read from the underlying reader.
+	BlocksRead int64
+	// ReadBlockTime is the time spent reading blocks, and TokenizeTime the
+	// rest of the time spent in Read. They are only measured while an
+	// observer is set.
+	ReadBlockTime time.Duration
+	TokenizeTime  time.Duration
+	// SkippedBytes is the number of bytes dropped because they are before
+	// LineStartingBy or in a skipped empty line.
+	SkippedBytes int64
+	// FilteredRows is the number of rows rejected by the filter, they are not
+	// in RowsParsed.
+	FilteredRows int64
+}
+
+// ProgressObserver is notified of the progress of a CSVParser. It's called by
+// the goroutine calling Read.
+type ProgressObserver interface {
+	OnProgress(stats ParserStats)
+}
+
+// progress holds the counters of a CSVParser. The counters are only touched by
+// the parsing goroutine, and copied into snapshot after each Read for Stats.
+type progress struct {
+	blocksRead    int64
+	readBlockTime time.Duration
+	tokenizeTime  time.Duration
+	skippedBytes  int64
+	rowsParsed    int64
+	filteredRows  int64
+
+	everyRows         int64
+	everyBytes        int64
+	lastNotifiedRows  int64
+	lastNotifiedBytes int64
+
+	mu       sync.Mutex
+	snapshot ParserStats
+}
+
+// SetObserver makes the parser call observer after every everyRows rows or
+// everyBytes bytes, whichever comes first. Zero disables the corresponding
+// trigger, and if both are zero observer is called after every row. A nil
+// observer stops the notifications.
+func (parser *CSVParser) SetObserver(observer ProgressObserver, everyRows, everyBytes int64) {
+	parser.observer = observer
+	parser.progress.everyRows = everyRows
+	parser.progress.everyBytes = everyBytes
+	parser.progress.lastNotifiedRows = parser.progress.rowsParsed
+	parser.progress.lastNotifiedBytes = parser.pos
+}
+
+// Stats returns the progress as of the last Read. Unlike Pos, it's safe to call
+// from other goroutines while the parser is in use.
+func (parser *CSVParser) Stats() ParserStats {
+	parser.progress.mu.Lock()
+	defer parser.progress.mu.Unlock()
+	return parser.progress.snapshot
+}
+
+// updateStats is called at the end of Read. If an observer is set, start is
+// when the Read began and blockTime the readBlockTime at that moment.
+func (parser *CSVParser) updateStats(start time.Time, blockTime time.Duration, err error) {
+	p := &parser.progress
+	if err == nil {
+		p.rowsParsed++
+	}
+	if parser.observer != nil {
+		// the time spent in readBlock is already in readBlockTime.
+		p.tokenizeTime += time.Since(start) - (p.readBlockTime - blockTime)
+	}
+
+	stats := ParserStats{
+		BytesConsumed: parser.pos,
+		RowsParsed:    p.rowsParsed,
+		BlocksRead:    p.blocksRead,
+		ReadBlockTime: p.readBlockTime,
+		TokenizeTime:  p.tokenizeTime,
+		SkippedBytes:  p.skippedBytes,
+		FilteredRows:  p.filteredRows,
+	}
+	p.mu.Lock()
+	p.snapshot = stats
+	p.mu.Unlock()
+
+	if parser.observer == nil || err != nil {
+		return
+	}
+	rowsTriggered := p.everyRows > 0 && p.rowsParsed-p.lastNotifiedRows >= p.everyRows
+	bytesTriggered := p.everyBytes > 0 && parser.pos-p.lastNotifiedBytes >= p.everyBytes
+	if rowsTriggered || bytesTriggered || (p.everyRows == 0 && p.everyBytes == 0) {
+		p.lastNotifiedRows = p.rowsParsed
+		p.lastNotifiedBytes = parser.pos
+		parser.observer.OnProgress(stats)
+	}
+}
@@ -0,0 +1,105 @@
+package mydump_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	stats []mydump.ParserStats
+}
+
+func (o *recordingObserver) OnProgress(stats mydump.ParserStats) {
+	o.stats = append(o.stats, stats)
+}
+
+func TestProgressObserver(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		LineTerminatedBy:  "\n",
+		LineStartingBy:    "xxx",
+	}
+	// "ignored\n" and "abc" are dropped by LineStartingBy.
+	input := "xxx1,2\nignored\nabcxxx3,4\nxxx5,6\nxxx7,8\n"
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), 2, false, false)
+	require.NoError(t, err)
+	observer := &recordingObserver{}
+	parser.SetObserver(observer, 2, 0)
+
+	for {
+		_, err = parser.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Len(t, observer.stats, 2)
+	require.Equal(t, int64(2), observer.stats[0].RowsParsed)
+	require.Equal(t, int64(25), observer.stats[0].BytesConsumed)
+	require.Equal(t, int64(11), observer.stats[0].SkippedBytes)
+	require.Equal(t, int64(4), observer.stats[1].RowsParsed)
+
+	stats := parser.Stats()
+	require.Equal(t, int64(4), stats.RowsParsed)
+	require.Equal(t, int64(len(input)), stats.BytesConsumed)
+	require.Equal(t, int64(11), stats.SkippedBytes)
+	require.Greater(t, stats.BlocksRead, int64(1))
+	require.True(t, stats.TokenizeTime > 0)
+
+	// empty lines are skipped bytes too, and the byte trigger works alone.
+	cfg = mydump.CSVConfig{FieldTerminatedBy: ","}
+	parser, err = mydump.NewCSVParser(&cfg, NewStringReader("a\n\n\n  \nb\nc\n"), 2, false, false)
+	require.NoError(t, err)
+	observer = &recordingObserver{}
+	parser.SetObserver(observer, 0, 5)
+	for {
+		if _, err = parser.Read(); err != nil {
+			break
+		}
+	}
+	require.Len(t, observer.stats, 1)
+	require.Equal(t, int64(2), observer.stats[0].RowsParsed)
+	require.Equal(t, int64(5), parser.Stats().SkippedBytes)
+}
+
+func TestStatsConcurrently(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ","}
+	input := strings.Repeat("a,b,c\n", 1000)
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), 16, false, true)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var last int64
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			stats := parser.Stats()
+			if stats.BytesConsumed < last {
+				t.Errorf("BytesConsumed went backward from %d to %d", last, stats.BytesConsumed)
+			}
+			last = stats.BytesConsumed
+		}
+	}()
+	for {
+		if _, err = parser.Read(); err != nil {
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, int64(1000), parser.Stats().RowsParsed)
+}
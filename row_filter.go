@@ -0,0 +1,110 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import "strings"
+
+// RowFilter decides whether a row is returned by Read. It's called before the
+// fields are unescaped, so rejecting a row costs no allocation.
+type RowFilter func(row RawRow) bool
+
+// RawRow gives access to the fields of a row as they are in the input. It's
+// only valid during the call to the RowFilter, since the fields alias a buffer
+// which the next record overwrites.
+type RawRow struct {
+	parser  *CSVParser
+	records []field
+}
+
+// Len returns the number of fields.
+func (r RawRow) Len() int {
+	return len(r.records)
+}
+
+// Raw returns the content of field i before the escape sequences, TrimSpace
+// and NULL values are processed, like Field.Raw. The string aliases the
+// buffer of the record, use strings.Clone to keep it after the filter returns.
+func (r RawRow) Raw(i int) string {
+	return r.records[i].content
+}
+
+// Quoted reports whether field i is quoted.
+func (r RawRow) Quoted(i int) bool {
+	return r.records[i].quoted
+}
+
+// Value unescapes field i, like Read does. Unlike Raw, the value is a copy which
+// can be kept.
+func (r RawRow) Value(i int) (Field, error) {
+	field, err := r.value(i)
+	field.Val = strings.Clone(field.Val)
+	return field, err
+}
+
+// value is Value without the copy, the value may alias the record.
+func (r RawRow) value(i int) (Field, error) {
+	val, isNull, err := r.parser.unescapeString(r.records[i])
+	return Field{Val: val, IsNull: isNull}, err
+}
+
+// Equal reports whether field i is not NULL and its value is value. The raw
+// content is compared when it's the same as the value, which is when it has
+// no escape sequence and isn't changed by TrimSpace or
+// NormalizeQuotedNewLines.
+func (r RawRow) Equal(i int, value string) bool {
+	if i < 0 || i >= len(r.records) {
+		return false
+	}
+	parser := r.parser
+	record := r.records[i]
+	isRaw := parser.cfg.TrimSpace == TrimSpaceNone && !parser.cfg.NormalizeQuotedNewLines &&
+		(len(parser.escapedBy) == 0 || strings.IndexByte(record.content, parser.escapedBy[0]) == -1)
+	if isRaw && record.content != value {
+		return false
+	}
+	field, err := r.value(i)
+	return err == nil && !field.IsNull && field.Val == value
+}
+
+// ColumnIndex returns the index of the column, or -1. The columns are only
+// known with HeaderSchemaMatch.
+func (r RawRow) ColumnIndex(column string) int {
+	for i, name := range r.parser.columns {
+		if strings.EqualFold(name, column) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetFilter makes Read skip the rows rejected by filter. The skipped rows are
+// counted in ParserStats.FilteredRows, and Pos moves past them like for the
+// returned rows. A nil filter returns all rows.
+func (parser *CSVParser) SetFilter(filter RowFilter) {
+	parser.filter = filter
+}
+
+// ColumnEquals returns a RowFilter keeping the rows where column equals
+// value, like `WHERE column = 'value'`. The column is found in the header,
+// which requires HeaderSchemaMatch.
+func ColumnEquals(column, value string) RowFilter {
+	index, resolved := -1, false
+	return func(row RawRow) bool {
+		if !resolved {
+			index, resolved = row.ColumnIndex(column), true
+		}
+		return row.Equal(index, value)
+	}
+}
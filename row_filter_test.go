@@ -0,0 +1,128 @@
+package mydump_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowFilter(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		FieldEscapedBy:    `\`,
+		Null:              []string{`\N`, "active"},
+		HeaderSchemaMatch: true,
+		QuotedNullIsText:  true,
+	}
+	input := "id,status\n" +
+		"1,\"active\"\n" +
+		"2,inactive\n" +
+		"3,\"act\\ive\"\n" +
+		"4,active\n" +
+		"5,\"active\"\n"
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), 4, true, false)
+	require.NoError(t, err)
+	parser.SetFilter(mydump.ColumnEquals("STATUS", "active"))
+
+	var ids []string
+	var rowIDs []int64
+	for {
+		row, meta, err := parser.ReadWithMeta()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		ids = append(ids, row[0].Val)
+		rowIDs = append(rowIDs, meta.RowID)
+	}
+	// row 4 is NULL, and row 3 only equals once unescaped.
+	require.Equal(t, []string{"1", "3", "5"}, ids)
+	require.Equal(t, []int64{1, 3, 5}, rowIDs)
+	require.Equal(t, int64(2), parser.Stats().FilteredRows)
+	require.Equal(t, int64(3), parser.Stats().RowsParsed)
+	require.Equal(t, int64(len(input)), parser.Pos())
+
+	// a raw filter, and an unknown column rejecting everything.
+	parser, err = mydump.NewCSVParser(&cfg, NewStringReader(input), 4, true, false)
+	require.NoError(t, err)
+	parser.SetFilter(func(row mydump.RawRow) bool {
+		return row.Len() == 2 && !row.Quoted(1) && strings.HasPrefix(row.Raw(1), "in")
+	})
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, "2", row[0].Val)
+	parser.SetFilter(mydump.ColumnEquals("missing", "active"))
+	_, err = parser.Read()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestRowFilterDoesNotAllocate(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`}
+	allocs := func(rows int) float64 {
+		input := []byte(strings.Repeat("1,\"some text\",x\n", rows))
+		return testing.AllocsPerRun(10, func() {
+			parser, err := mydump.NewCSVParserFromBytes(&cfg, input, false, false)
+			require.NoError(t, err)
+			parser.SetFilter(func(row mydump.RawRow) bool {
+				return row.Raw(2) == "y"
+			})
+			_, err = parser.Read()
+			require.Equal(t, io.EOF, err)
+		})
+	}
+	require.Equal(t, allocs(10), allocs(1000))
+}
+
+func TestRawRowValueIsCopied(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ","}
+	parser, err := mydump.NewCSVParserFromBytes(&cfg, []byte("a,1\nb,2\nc,3\n"), false, false)
+	require.NoError(t, err)
+	var kept []string
+	parser.SetFilter(func(row mydump.RawRow) bool {
+		field, err := row.Value(0)
+		require.NoError(t, err)
+		kept = append(kept, field.Val)
+		return false
+	})
+	_, err = parser.Read()
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, []string{"a", "b", "c"}, kept)
+}
+
+func BenchmarkReadRowFilter(b *testing.B) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`}
+	var sb strings.Builder
+	for i := 0; i < 10000; i++ {
+		status := "inactive"
+		if i%100 == 0 {
+			status = "active"
+		}
+		sb.WriteString(`1,"some longer text, with a separator",2024-01-01,` + status + "\n")
+	}
+	input := []byte(sb.String())
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser, err := mydump.NewCSVParserFromBytes(&cfg, input, false, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		parser.SetFilter(func(row mydump.RawRow) bool {
+			return row.Raw(3) == "active"
+		})
+		for {
+			_, err = parser.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
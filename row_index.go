@@ -0,0 +1,193 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// rowIndexMagic starts every serialized RowIndex, the last byte is the format
+// version.
+var rowIndexMagic = []byte("CSVRIDX\x01")
+
+// maxPreallocatedOffsets caps the offsets allocated before they are read.
+const maxPreallocatedOffsets = 1 << 16
+
+// RowIndex records where every Interval-th row of a CSV file starts, so that a
+// row can be reached without parsing all the rows before it.
+type RowIndex struct {
+	// Interval is the number of rows between two indexed offsets.
+	Interval int64
+	// Offsets[i] is the Pos() before reading row i*Interval. Rows are counted
+	// from 0 and the header is not a row.
+	Offsets []int64
+	// Rows is the number of rows in the file.
+	Rows int64
+}
+
+// BuildRowIndex reads all rows of a freshly created parser and records the
+// position of every interval-th row. Rows are only tokenized, not unescaped.
+// Since positions come from the parser itself, rows containing quoted newlines
+// are indexed at their real start.
+func BuildRowIndex(parser *CSVParser, interval int64) (*RowIndex, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid row index interval %d", interval)
+	}
+	idx := &RowIndex{Interval: interval}
+	err := parser.readHeader()
+	if errors.Is(err, io.EOF) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for {
+		pos := parser.pos
+		records, err := parser.readRecord(parser.lastRecord)
+		if errors.Is(err, io.EOF) {
+			return idx, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		parser.lastRecord = records
+		if idx.Rows%interval == 0 {
+			idx.Offsets = append(idx.Offsets, pos)
+		}
+		idx.Rows++
+	}
+}
+
+// readHeader reads the sep= line and the header, unless they are already read.
+func (parser *CSVParser) readHeader() error {
+	if parser.shouldReadSepHint {
+		if err := parser.readSepHint(); err != nil {
+			return err
+		}
+		parser.shouldReadSepHint = false
+	}
+	if parser.shouldParseHeader {
+		if err := parser.readColumns(); err != nil {
+			return err
+		}
+		parser.shouldParseHeader = false
+	}
+	return nil
+}
+
+// WriteTo serializes the index to w.
+func (idx *RowIndex) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, len(rowIndexMagic)+3*binary.MaxVarintLen64+len(idx.Offsets)*2)
+	buf = append(buf, rowIndexMagic...)
+	buf = binary.AppendUvarint(buf, uint64(idx.Interval))
+	buf = binary.AppendUvarint(buf, uint64(idx.Rows))
+	buf = binary.AppendUvarint(buf, uint64(len(idx.Offsets)))
+	// offsets are increasing, so store the deltas to keep the file small.
+	var prev int64
+	for _, offset := range idx.Offsets {
+		buf = binary.AppendUvarint(buf, uint64(offset-prev))
+		prev = offset
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadRowIndex reads an index serialized by RowIndex.WriteTo.
+func ReadRowIndex(r io.Reader) (*RowIndex, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(rowIndexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, rowIndexMagic) {
+		return nil, errors.New("invalid row index: bad magic")
+	}
+	var header [3]uint64
+	for i := range header {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row index: %w", err)
+		}
+		header[i] = v
+	}
+	interval, rows, count := header[0], header[1], header[2]
+	if interval == 0 || interval > math.MaxInt64 || rows > math.MaxInt64 ||
+		count != rows/interval+min(rows%interval, 1) {
+		return nil, errors.New("invalid row index: inconsistent header")
+	}
+	idx := &RowIndex{
+		Interval: int64(interval),
+		Rows:     int64(rows),
+	}
+	// the count is only trusted once the offsets are read, so a corrupted
+	// header can't make us allocate much.
+	idx.Offsets = make([]int64, 0, min(count, maxPreallocatedOffsets))
+	var prev int64
+	for i := uint64(0); i < count; i++ {
+		delta, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row index: %w", err)
+		}
+		if delta > uint64(math.MaxInt64-prev) {
+			return nil, errors.New("invalid row index: offset overflow")
+		}
+		prev += int64(delta)
+		idx.Offsets = append(idx.Offsets, prev)
+	}
+	return idx, nil
+}
+
+// SeekRow moves the parser so that the next Read returns row n, counted from
+// 0 excluding the header. It jumps to the closest indexed row before n and
+// parses forward from there. The parser must read from an io.Seeker or be
+// created by NewCSVParserFromBytes, and idx must be built from the same file
+// and config. It returns io.EOF if the file has no row n. If the parser hasn't
+// read the header yet, it's read first so that Columns returns it.
+func (parser *CSVParser) SeekRow(idx *RowIndex, n int64) error {
+	if n < 0 {
+		return fmt.Errorf("invalid row number %d", n)
+	}
+	if idx.Interval <= 0 {
+		return fmt.Errorf("invalid row index interval %d", idx.Interval)
+	}
+	if n >= idx.Rows {
+		return io.EOF
+	}
+	i := n / idx.Interval
+	if i >= int64(len(idx.Offsets)) {
+		return fmt.Errorf("invalid row index: no offset for row %d", n)
+	}
+	if err := parser.readHeader(); err != nil {
+		return err
+	}
+	if err := parser.seek(idx.Offsets[i]); err != nil {
+		return err
+	}
+	for skip := n - i*idx.Interval; skip > 0; skip-- {
+		records, err := parser.readRecord(parser.lastRecord)
+		if err != nil {
+			return err
+		}
+		parser.lastRecord = records
+	}
+	parser.rowID = n
+	return nil
+}
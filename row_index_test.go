@@ -0,0 +1,136 @@
+package mydump_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowIndexSeekRow(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+	}
+	var b strings.Builder
+	b.WriteString("\xEF\xBB\xBFid,comment\r\n")
+	for i := 0; i < 10; i++ {
+		if i%3 == 1 {
+			fmt.Fprintf(&b, "%d,\"multi\nline %d\"\r\n", i, i)
+		} else {
+			fmt.Fprintf(&b, "%d,plain %d\r\n\r\n", i, i)
+		}
+	}
+	input := b.String()
+
+	parser, err := mydump.NewCSVParser(&cfg, strings.NewReader(input), 16, true, false)
+	require.NoError(t, err)
+	expected := readAllResults(t, parser)
+	require.Len(t, expected, 11)
+	require.Equal(t, io.EOF, expected[10].err)
+
+	parser, err = mydump.NewCSVParser(&cfg, strings.NewReader(input), 16, true, false)
+	require.NoError(t, err)
+	idx, err := mydump.BuildRowIndex(parser, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), idx.Rows)
+	require.Len(t, idx.Offsets, 4)
+
+	var saved bytes.Buffer
+	_, err = idx.WriteTo(&saved)
+	require.NoError(t, err)
+	loaded, err := mydump.ReadRowIndex(&saved)
+	require.NoError(t, err)
+	require.Equal(t, idx, loaded)
+
+	reader, err := mydump.NewCSVParser(&cfg, strings.NewReader(input), 16, true, false)
+	require.NoError(t, err)
+	inMemory, err := mydump.NewCSVParserFromBytes(&cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	for _, parser := range []*mydump.CSVParser{reader, inMemory} {
+		for _, n := range []int64{7, 0, 9, 3, 4, 1} {
+			require.NoError(t, parser.SeekRow(loaded, n))
+			row, err := parser.Read()
+			require.NoError(t, err)
+			require.Equal(t, expected[n].row, row, n)
+			require.Equal(t, expected[n].pos, parser.Pos(), n)
+		}
+		require.Equal(t, io.EOF, parser.SeekRow(loaded, 10))
+	}
+
+	_, err = mydump.ReadRowIndex(strings.NewReader("not an index"))
+	require.Error(t, err)
+}
+
+func TestReadCorruptedRowIndex(t *testing.T) {
+	header := func(values ...uint64) []byte {
+		buf := []byte("CSVRIDX\x01")
+		for _, v := range values {
+			buf = binary.AppendUvarint(buf, v)
+		}
+		return buf
+	}
+	cases := []struct {
+		data []byte
+		err  string
+	}{
+		// a huge count must not be allocated before the offsets are read.
+		{header(1, math.MaxInt64, math.MaxInt64, 3), "invalid row index: EOF"},
+		{header(2, 3, 2, 5), "invalid row index: EOF"},
+		// Rows+Interval-1 would overflow.
+		{header(math.MaxInt64, math.MaxInt64, 1), "invalid row index: EOF"},
+		{header(1, 2, 2, math.MaxInt64, 1), "invalid row index: offset overflow"},
+	}
+	for _, tc := range cases {
+		_, err := mydump.ReadRowIndex(bytes.NewReader(tc.data))
+		require.EqualError(t, err, tc.err)
+	}
+	for _, values := range [][]uint64{{0, 0, 0}, {1 << 63, 1, 1}, {1, 1 << 63, 1 << 63}} {
+		_, err := mydump.ReadRowIndex(bytes.NewReader(header(values...)))
+		require.EqualError(t, err, "invalid row index: inconsistent header", values)
+	}
+}
+
+func TestRowIndexSepHint(t *testing.T) {
+	cfg := mydump.NewExcelConfig()
+	input := "sep=;\nid;v\n1;a\n2;b\n3;c\n"
+	parser, err := mydump.NewCSVParserFromBytes(cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	idx, err := mydump.BuildRowIndex(parser, 2)
+	require.NoError(t, err)
+	require.Equal(t, &mydump.RowIndex{Interval: 2, Offsets: []int64{11, 19}, Rows: 3}, idx)
+
+	cfg.HeaderSchemaMatch = true
+	parser, err = mydump.NewCSVParser(cfg, strings.NewReader(input), 1, true, false)
+	require.NoError(t, err)
+	require.NoError(t, parser.SeekRow(idx, 1))
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{newStringField("2", false), newStringField("b", false)}, row)
+	require.Equal(t, []string{"id", "v"}, parser.Columns())
+}
+
+func TestSeekRowInvalidIndex(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", HeaderSchemaMatch: true}
+	parser, err := mydump.NewCSVParserFromBytes(&cfg, []byte("a\n1\n2\n3\n"), true, false)
+	require.NoError(t, err)
+	err = parser.SeekRow(&mydump.RowIndex{Offsets: []int64{2}, Rows: 3}, 1)
+	require.EqualError(t, err, "invalid row index interval 0")
+	err = parser.SeekRow(&mydump.RowIndex{Interval: -1, Offsets: []int64{2}, Rows: 3}, 1)
+	require.EqualError(t, err, "invalid row index interval -1")
+	err = parser.SeekRow(&mydump.RowIndex{Interval: 1, Offsets: []int64{2}, Rows: 3}, 2)
+	require.EqualError(t, err, "invalid row index: no offset for row 2")
+
+	// the header is read before seeking.
+	require.NoError(t, parser.SeekRow(&mydump.RowIndex{Interval: 2, Offsets: []int64{2, 6}, Rows: 3}, 1))
+	require.Equal(t, []string{"a"}, parser.Columns())
+	row, err := parser.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{newStringField("2", false)}, row)
+}
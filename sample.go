@@ -0,0 +1,276 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sort"
+)
+
+// tailWindowSize is the size of the end of the input first read by Tail, it's
+// doubled until the window holds enough rows.
+var tailWindowSize int64 = 64 * 1024
+
+// Head returns the first n rows of reader.
+func Head(reader RowReader, n int) ([][]Field, error) {
+	rows := make([][]Field, 0, n)
+	for len(rows) < n {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, slices.Clone(row))
+	}
+	return rows, nil
+}
+
+// EveryNth returns a RowFilter keeping the rows 0, n, 2n... counted from 0
+// among the rows reaching the filter. n must be positive.
+func EveryNth(n int64) (RowFilter, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid sampling interval %d", n)
+	}
+	var i int64
+	return func(RawRow) bool {
+		keep := i%n == 0
+		i++
+		return keep
+	}, nil
+}
+
+// ReservoirSample reads all the rows of parser and returns k of them picked
+// uniformly at random, in the order of the input. The same seed picks the
+// same rows of the same input. The rows which aren't picked are only
+// tokenized, and counted in ParserStats.FilteredRows like the rows rejected by
+// the filter of the parser, which is still applied first.
+func ReservoirSample(parser *CSVParser, k int, seed int64) ([][]Field, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("invalid sample size %d", k)
+	}
+	rng := rand.New(rand.NewSource(seed))
+	rows := make([][]Field, 0, k)
+	// rowNums[i] is the number of rows[i] among the sampled rows.
+	rowNums := make([]int64, 0, k)
+	var (
+		seen int64
+		slot int
+	)
+	prevFilter := parser.filter
+	defer parser.SetFilter(prevFilter)
+	// this is Algorithm R, the slot of the row is decided before the row is
+	// unescaped, so that the rows which are not picked cost nothing.
+	parser.SetFilter(func(row RawRow) bool {
+		if prevFilter != nil && !prevFilter(row) {
+			return false
+		}
+		slot = int(seen)
+		if seen >= int64(k) {
+			j := rng.Int63n(seen + 1)
+			slot = int(j)
+			if j >= int64(k) {
+				seen++
+				return false
+			}
+		}
+		seen++
+		return true
+	})
+	for {
+		row, err := parser.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row = slices.Clone(row)
+		if slot == len(rows) {
+			rows = append(rows, row)
+			rowNums = append(rowNums, seen-1)
+		} else {
+			rows[slot] = row
+			rowNums[slot] = seen - 1
+		}
+	}
+	sort.Sort(sampleRows{rows: rows, rowNums: rowNums})
+	return rows, nil
+}
+
+type sampleRows struct {
+	rows    [][]Field
+	rowNums []int64
+}
+
+func (s sampleRows) Len() int           { return len(s.rows) }
+func (s sampleRows) Less(i, j int) bool { return s.rowNums[i] < s.rowNums[j] }
+func (s sampleRows) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+	s.rowNums[i], s.rowNums[j] = s.rowNums[j], s.rowNums[i]
+}
+
+// Tail returns the last n rows of r without parsing the whole input.
+//
+// With idx, built by BuildRowIndex from the same file and config, it seeks to
+// the indexed row before the last n rows. Without it, it parses a window at
+// the end of r from the first line terminator which isn't escaped, and
+// doubles the window until it holds n rows, up to the whole input.
+//
+// If FieldEnclosedBy is set, a line terminator may be inside a quoted field.
+// Parsing from there reads every quote of the window the wrong way round, so
+// it fails at a quote in the middle of a field or at the end of the window,
+// which is inside a quote. The window is then resynced on the next line
+// terminator which parses to the end of the window without error. This is
+// best effort: quotes which parse both ways can still resync inside a quoted
+// field, so use an idx to be exact.
+func Tail(cfg *CSVConfig, r io.ReadSeeker, n int, shouldParseHeader bool, idx *RowIndex) ([][]Field, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	if idx != nil {
+		parser, err := NewCSVParser(cfg, r, ReadBlockSize, shouldParseHeader, false)
+		if err != nil {
+			return nil, err
+		}
+		if idx.Rows == 0 {
+			return nil, nil
+		}
+		if err = parser.SeekRow(idx, max(idx.Rows-int64(n), 0)); err != nil {
+			return nil, err
+		}
+		return tailRows(parser, n)
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	// the window doesn't start with the `sep=` line, so it's read first.
+	var comma []byte
+	if cfg.SepHint {
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		first, err := NewCSVParser(cfg, r, ReadBlockSize, false, false)
+		if err != nil {
+			return nil, err
+		}
+		if err = first.readSepHint(); err != nil {
+			return nil, err
+		}
+		comma = first.comma
+	}
+
+	for window := tailWindowSize; ; window *= 2 {
+		start := max(size-window, 0)
+		if _, err = r.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		data := make([]byte, size-start)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if start == 0 {
+			parser, err := NewCSVParserFromBytes(cfg, data, shouldParseHeader, false)
+			if err != nil {
+				return nil, err
+			}
+			return tailRows(parser, n)
+		}
+		for offset := nextRowStart(cfg, data, 0); offset >= 0; offset = nextRowStart(cfg, data, offset) {
+			parser, err := NewCSVParserFromBytes(cfg, data, false, false)
+			if err != nil {
+				return nil, err
+			}
+			if err = parser.seek(int64(offset)); err != nil {
+				return nil, err
+			}
+			if comma != nil {
+				parser.setComma(comma)
+			}
+			parser.shouldReadSepHint = false
+			rows, err := tailRows(parser, n)
+			if err != nil && len(cfg.FieldEnclosedBy) > 0 {
+				// offset may be inside a quoted field.
+				continue
+			}
+			if err != nil || len(rows) == n {
+				return rows, err
+			}
+			break
+		}
+	}
+}
+
+// tailRows returns the last n rows of parser.
+func tailRows(parser *CSVParser, n int) ([][]Field, error) {
+	// rows is a ring, the next row goes at count%n.
+	rows := make([][]Field, n)
+	count := 0
+	for {
+		row, err := parser.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows[count%n] = row
+		count++
+	}
+	if count < n {
+		return rows[:count], nil
+	}
+	i := count % n
+	return append(rows[i:], rows[:i]...), nil
+}
+
+// nextRowStart returns the offset after the first line terminator of data
+// from i which isn't escaped, or -1. The terminator may be inside quotes.
+func nextRowStart(cfg *CSVConfig, data []byte, i int) int {
+	terminator := []byte(cfg.lineTerminator())
+	for i < len(data) {
+		var j, n int
+		if len(terminator) == 0 {
+			j, n = bytes.IndexAny(data[i:], "\r\n"), 1
+		} else {
+			j, n = bytes.Index(data[i:], terminator), len(terminator)
+		}
+		if j < 0 {
+			return -1
+		}
+		j += i
+		i = j + n
+		if len(cfg.FieldEscapedBy) == 0 {
+			return i
+		}
+		// the terminator is escaped after an odd number of escapes, and
+		// unknown if the escapes go back to the start of the window.
+		escapes := 0
+		for j-escapes > 0 && data[j-escapes-1] == cfg.FieldEscapedBy[0] {
+			escapes++
+		}
+		if j-escapes > 0 && escapes%2 == 0 {
+			return i
+		}
+	}
+	return -1
+}
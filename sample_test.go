@@ -0,0 +1,153 @@
+package mydump_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleInput(rows int) string {
+	var b strings.Builder
+	b.WriteString("id,comment\n")
+	for i := 0; i < rows; i++ {
+		if i%4 == 1 {
+			// the lines inside the quotes look like rows.
+			fmt.Fprintf(&b, "%d,\"x\ny,z\n%d,w\"\n", i, i)
+		} else {
+			fmt.Fprintf(&b, "%d,plain %d\n", i, i)
+		}
+	}
+	return b.String()
+}
+
+func TestHeadAndEveryNth(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`}
+	parser, err := mydump.NewCSVParserFromBytes(&cfg, []byte(sampleInput(10)), true, false)
+	require.NoError(t, err)
+	everyThird, err := mydump.EveryNth(3)
+	require.NoError(t, err)
+	parser.SetFilter(everyThird)
+	rows, err := mydump.Head(parser, 3)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	for i, id := range []string{"0", "3", "6"} {
+		require.Equal(t, id, rows[i][0].Val)
+	}
+	rows, err = mydump.Head(parser, 3)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, "9", rows[0][0].Val)
+
+	for _, n := range []int64{0, -1} {
+		_, err = mydump.EveryNth(n)
+		require.EqualError(t, err, fmt.Sprintf("invalid sampling interval %d", n))
+	}
+}
+
+func TestReservoirSample(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`}
+	input := []byte(sampleInput(1000))
+	sample := func(k int, seed int64) []string {
+		parser, err := mydump.NewCSVParserFromBytes(&cfg, input, true, true)
+		require.NoError(t, err)
+		rows, err := mydump.ReservoirSample(parser, k, seed)
+		require.NoError(t, err)
+		ids := make([]string, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row[0].Val)
+		}
+		// the rows replaced in the reservoir are unescaped too.
+		require.LessOrEqual(t, parser.Stats().FilteredRows, int64(1000-len(rows)))
+		return ids
+	}
+
+	ids := sample(10, 42)
+	require.Len(t, ids, 10)
+	require.Equal(t, ids, sample(10, 42))
+	require.NotEqual(t, ids, sample(10, 43))
+	prev := -1
+	for _, id := range ids {
+		var n int
+		_, err := fmt.Sscan(id, &n)
+		require.NoError(t, err)
+		require.Greater(t, n, prev)
+		prev = n
+	}
+	require.Len(t, sample(2000, 1), 1000)
+
+	_, err := mydump.ReservoirSample(nil, 0, 1)
+	require.Error(t, err)
+}
+
+func TestTail(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ",", FieldEnclosedBy: `"`}
+	input := sampleInput(40)
+	parser, err := mydump.NewCSVParserFromBytes(&cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	all, err := mydump.Head(parser, 100)
+	require.NoError(t, err)
+	require.Len(t, all, 40)
+
+	// the lines inside the quotes don't parse to the end of the window.
+	for window := int64(1); window < int64(len(input)); window += 5 {
+		restore := mydump.SetTailWindowSize(window)
+		rows, err := mydump.Tail(&cfg, strings.NewReader(input), 5, true, nil)
+		restore()
+		require.NoError(t, err)
+		require.Equal(t, all[35:], rows, window)
+	}
+	// an error is returned once the whole input fails to parse.
+	restore := mydump.SetTailWindowSize(16)
+	_, err = mydump.Tail(&cfg, strings.NewReader(input+"41,\"unterminated\n"), 5, true, nil)
+	restore()
+	require.Contains(t, err.Error(), "unterminated quoted field")
+	parser, err = mydump.NewCSVParserFromBytes(&cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	idx, err := mydump.BuildRowIndex(parser, 7)
+	require.NoError(t, err)
+	for _, n := range []int{1, 5, 35, 40, 100} {
+		rows, err := mydump.Tail(&cfg, strings.NewReader(input), n, true, idx)
+		require.NoError(t, err)
+		require.Equal(t, all[max(40-n, 0):], rows, n)
+	}
+
+	// without quotes, a line terminator is a row boundary unless it's escaped.
+	cfg = mydump.CSVConfig{FieldTerminatedBy: ";", FieldEscapedBy: `\`, SepHint: true}
+	var b strings.Builder
+	b.WriteString("sep=,\nid,comment\n")
+	for i := 0; i < 40; i++ {
+		switch i % 3 {
+		case 0:
+			fmt.Fprintf(&b, "%d,escaped\\\n%d,newline\n", i, i)
+		case 1:
+			fmt.Fprintf(&b, "%d,escaped backslash\\\\\n", i)
+		default:
+			fmt.Fprintf(&b, "%d,plain\n", i)
+		}
+	}
+	input = b.String()
+	parser, err = mydump.NewCSVParserFromBytes(&cfg, []byte(input), true, false)
+	require.NoError(t, err)
+	all, err = mydump.Head(parser, 100)
+	require.NoError(t, err)
+	require.Len(t, all, 40)
+	require.Equal(t, "escaped\n0", all[0][1].Val)
+
+	for window := int64(1); window < int64(len(input)); window += 3 {
+		restore := mydump.SetTailWindowSize(window)
+		rows, err := mydump.Tail(&cfg, strings.NewReader(input), 5, true, nil)
+		restore()
+		require.NoError(t, err)
+		require.Equal(t, all[35:], rows, window)
+	}
+
+	rows, err := mydump.Tail(&cfg, strings.NewReader(input), 100, true, nil)
+	require.NoError(t, err)
+	require.Equal(t, all, rows)
+	rows, err = mydump.Tail(&cfg, strings.NewReader(""), 5, true, nil)
+	require.NoError(t, err)
+	require.Empty(t, rows)
+}
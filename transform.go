@@ -0,0 +1,406 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TransformFunc is a stage of a TransformReader. It may modify row in place.
+type TransformFunc func(row []Field) ([]Field, error)
+
+// Transform is a named TransformFunc, bound to the columns of its input.
+type Transform struct {
+	Name string
+	// Bind is called once the columns of the input are known, they are nil if
+	// the input has no header. It returns the stage and the columns of its
+	// output.
+	Bind func(columns []string) (TransformFunc, []string, error)
+}
+
+// RowWriter is implemented by the writers of every supported format.
+type RowWriter interface {
+	Write(row []Field) error
+	Flush() error
+}
+
+// HeaderWriter is implemented by the RowWriters which can write a header, Copy
+// writes the columns with it.
+type HeaderWriter interface {
+	WriteHeader(columns []string) error
+}
+
+var (
+	_ RowWriter    = (*CSVWriter)(nil)
+	_ RowWriter    = (*InsertWriter)(nil)
+	_ RowWriter    = (*JSONLinesEncoder)(nil)
+	_ HeaderWriter = (*CSVWriter)(nil)
+)
+
+// TransformReader applies a chain of transforms to the rows of a RowReader.
+// Its Columns are the ones of the last transform.
+type TransformReader struct {
+	src        RowReader
+	transforms []Transform
+	funcs      []TransformFunc
+	columns    []string
+	isBound    bool
+	// bindErr is returned by every Read once a transform failed to bind.
+	bindErr error
+}
+
+var _ RowReader = (*TransformReader)(nil)
+
+// NewTransformReader creates a TransformReader. The transforms are bound on
+// the first Read, once the header of src is parsed.
+func NewTransformReader(src RowReader, transforms ...Transform) *TransformReader {
+	return &TransformReader{src: src, transforms: transforms}
+}
+
+func (r *TransformReader) bind() error {
+	columns := r.src.Columns()
+	funcs := make([]TransformFunc, 0, len(r.transforms))
+	for _, t := range r.transforms {
+		fn, out, err := t.Bind(columns)
+		if err != nil {
+			return fmt.Errorf("transform %s: %w", t.Name, err)
+		}
+		funcs = append(funcs, fn)
+		columns = out
+	}
+	r.funcs = funcs
+	r.columns = columns
+	r.isBound = true
+	return nil
+}
+
+// Read returns the next transformed row. If a transform fails to bind, Read
+// returns the same error from then on.
+func (r *TransformReader) Read() ([]Field, error) {
+	if r.bindErr != nil {
+		return nil, r.bindErr
+	}
+	row, err := r.src.Read()
+	if !r.isBound && (err == nil || errors.Is(err, io.EOF)) {
+		if r.bindErr = r.bind(); r.bindErr != nil {
+			return nil, r.bindErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, fn := range r.funcs {
+		if row, err = fn(row); err != nil {
+			return nil, fmt.Errorf("transform %s: %w", r.transforms[i].Name, err)
+		}
+	}
+	return row, nil
+}
+
+// Pos returns the position of src, after the last row read.
+func (r *TransformReader) Pos() int64 {
+	return r.src.Pos()
+}
+
+// Columns returns the columns of the transformed rows, or nil before the first
+// Read.
+func (r *TransformReader) Columns() []string {
+	return r.columns
+}
+
+// Copy writes the rows of src, transformed, to dst and flushes it. If dst is a
+// HeaderWriter and the transformed rows have columns, they are written first.
+// It returns the number of rows written.
+func Copy(dst RowWriter, src RowReader, transforms ...Transform) (int64, error) {
+	reader := NewTransformReader(src, transforms...)
+	var rows int64
+	for {
+		row, err := reader.Read()
+		if rows == 0 && (err == nil || errors.Is(err, io.EOF)) {
+			if hw, ok := dst.(HeaderWriter); ok && reader.Columns() != nil {
+				if err2 := hw.WriteHeader(reader.Columns()); err2 != nil {
+					return rows, err2
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		if err = dst.Write(row); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	return rows, dst.Flush()
+}
+
+// resolveColumn returns the index of the column ref, which is either a column
+// name, or "#i" for the i-th column counted from 0. The index is only checked
+// against columns if they are known.
+func resolveColumn(columns []string, ref string) (int, error) {
+	if strings.HasPrefix(ref, "#") {
+		i, err := strconv.Atoi(ref[1:])
+		if err != nil || i < 0 {
+			return 0, fmt.Errorf("invalid column %s", ref)
+		}
+		if columns != nil && i >= len(columns) {
+			return 0, fmt.Errorf("column %s is out of the %d columns", ref, len(columns))
+		}
+		return i, nil
+	}
+	for i, name := range columns {
+		if strings.EqualFold(name, ref) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("column %s is not in the columns %v", ref, columns)
+}
+
+func resolveColumns(columns []string, refs []string) ([]int, error) {
+	indexes := make([]int, 0, len(refs))
+	for _, ref := range refs {
+		i, err := resolveColumn(columns, ref)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// MapRows returns a transform applying fn, which keeps the columns.
+func MapRows(name string, fn TransformFunc) Transform {
+	return Transform{Name: name, Bind: func(columns []string) (TransformFunc, []string, error) {
+		return fn, columns, nil
+	}}
+}
+
+// mapValues returns a transform replacing the non-NULL values of the columns,
+// or of all the columns if none is given, by fn(value).
+func mapValues(name string, refs []string, fn func(string) string) Transform {
+	return Transform{Name: name, Bind: func(columns []string) (TransformFunc, []string, error) {
+		indexes, err := resolveColumns(columns, refs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(row []Field) ([]Field, error) {
+			if len(refs) == 0 {
+				for i := range row {
+					if !row[i].IsNull {
+						row[i].Val = fn(row[i].Val)
+					}
+				}
+				return row, nil
+			}
+			for _, i := range indexes {
+				if i < len(row) && !row[i].IsNull {
+					row[i].Val = fn(row[i].Val)
+				}
+			}
+			return row, nil
+		}, columns, nil
+	}}
+}
+
+// TrimColumns trims the spaces around the values of the columns, or of all
+// the columns if none is given.
+func TrimColumns(columns ...string) Transform {
+	return mapValues("trim", columns, strings.TrimSpace)
+}
+
+// UpperColumns upper-cases the values of the columns, or of all the columns if
+// none is given.
+func UpperColumns(columns ...string) Transform {
+	return mapValues("upper", columns, strings.ToUpper)
+}
+
+// LowerColumns lower-cases the values of the columns, or of all the columns if
+// none is given.
+func LowerColumns(columns ...string) Transform {
+	return mapValues("lower", columns, strings.ToLower)
+}
+
+// MaskColumn replaces the characters of the values of column by '*', except
+// the last keep ones, like ************1234 for a card number.
+func MaskColumn(column string, keep int) Transform {
+	return mapValues("mask", []string{column}, func(val string) string {
+		n := utf8.RuneCountInString(val) - keep
+		if n <= 0 {
+			return val
+		}
+		var sb strings.Builder
+		sb.Grow(len(val))
+		for i := range val {
+			if n == 0 {
+				sb.WriteString(val[i:])
+				break
+			}
+			sb.WriteByte('*')
+			n--
+		}
+		return sb.String()
+	})
+}
+
+// SplitColumn replaces column by the columns names, holding the parts of its
+// value split by sep. The last part holds the rest of the value, and the
+// missing parts are NULL.
+func SplitColumn(column, sep string, names ...string) Transform {
+	return Transform{Name: "split", Bind: func(columns []string) (TransformFunc, []string, error) {
+		if len(names) == 0 {
+			return nil, nil, errors.New("no column to split into")
+		}
+		index, err := resolveColumn(columns, column)
+		if err != nil {
+			return nil, nil, err
+		}
+		var out []string
+		if columns != nil {
+			out = append(append(slices.Clone(columns[:index]), names...), columns[index+1:]...)
+		}
+		return func(row []Field) ([]Field, error) {
+			if index >= len(row) {
+				return nil, fmt.Errorf("row has %d fields, %s is missing", len(row), column)
+			}
+			split := make([]Field, 0, len(row)-1+len(names))
+			split = append(split, row[:index]...)
+			var parts []string
+			if !row[index].IsNull {
+				parts = strings.SplitN(row[index].Val, sep, len(names))
+			}
+			for i := range names {
+				if i < len(parts) {
+					split = append(split, Field{Val: parts[i]})
+				} else {
+					split = append(split, Field{IsNull: true})
+				}
+			}
+			return append(split, row[index+1:]...), nil
+		}, out, nil
+	}}
+}
+
+// AddColumn appends a column named name, computed from the row.
+func AddColumn(name string, compute func(row []Field) (Field, error)) Transform {
+	return Transform{Name: "add " + name, Bind: func(columns []string) (TransformFunc, []string, error) {
+		var out []string
+		if columns != nil {
+			out = append(slices.Clip(columns), name)
+		}
+		return func(row []Field) ([]Field, error) {
+			field, err := compute(row)
+			if err != nil {
+				return nil, err
+			}
+			return append(row, field), nil
+		}, out, nil
+	}}
+}
+
+// DropColumns removes the columns.
+func DropColumns(columns ...string) Transform {
+	return Transform{Name: "drop", Bind: func(in []string) (TransformFunc, []string, error) {
+		indexes, err := resolveColumns(in, columns)
+		if err != nil {
+			return nil, nil, err
+		}
+		var out []string
+		for i, name := range in {
+			if !slices.Contains(indexes, i) {
+				out = append(out, name)
+			}
+		}
+		return func(row []Field) ([]Field, error) {
+			kept := row[:0]
+			for i, field := range row {
+				if !slices.Contains(indexes, i) {
+					kept = append(kept, field)
+				}
+			}
+			return kept, nil
+		}, out, nil
+	}}
+}
+
+// RenameColumn renames the column from to to.
+func RenameColumn(from, to string) Transform {
+	return Transform{Name: "rename", Bind: func(columns []string) (TransformFunc, []string, error) {
+		index, err := resolveColumn(columns, from)
+		if err != nil {
+			return nil, nil, err
+		}
+		var out []string
+		if columns != nil {
+			out = slices.Clone(columns)
+			out[index] = to
+		}
+		return func(row []Field) ([]Field, error) {
+			return row, nil
+		}, out, nil
+	}}
+}
+
+// TransformByName returns the built-in transform name, configured by args, so
+// that transforms can be read from a configuration:
+//
+//	trim [column...]
+//	upper [column...]
+//	lower [column...]
+//	mask column keep
+//	split column sep name...
+//	drop column...
+//	rename from to
+func TransformByName(name string, args ...string) (Transform, error) {
+	switch name {
+	case "trim":
+		return TrimColumns(args...), nil
+	case "upper":
+		return UpperColumns(args...), nil
+	case "lower":
+		return LowerColumns(args...), nil
+	case "mask":
+		if len(args) != 2 {
+			return Transform{}, errors.New("mask needs a column and the number of characters to keep")
+		}
+		keep, err := strconv.Atoi(args[1])
+		if err != nil {
+			return Transform{}, fmt.Errorf("mask: %w", err)
+		}
+		return MaskColumn(args[0], keep), nil
+	case "split":
+		if len(args) < 3 {
+			return Transform{}, errors.New("split needs a column, a separator and the new columns")
+		}
+		return SplitColumn(args[0], args[1], args[2:]...), nil
+	case "drop":
+		return DropColumns(args...), nil
+	case "rename":
+		if len(args) != 2 {
+			return Transform{}, errors.New("rename needs the old and the new name")
+		}
+		return RenameColumn(args[0], args[1]), nil
+	default:
+		return Transform{}, fmt.Errorf("unknown transform %s", name)
+	}
+}
@@ -0,0 +1,97 @@
+package mydump_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	mydump "csvReader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyWithTransforms(t *testing.T) {
+	cfg := mydump.CSVConfig{
+		FieldTerminatedBy: ",",
+		FieldEnclosedBy:   `"`,
+		Null:              []string{"NULL"},
+		HeaderSchemaMatch: true,
+	}
+	input := "Name,Card,Full,Tmp\n" +
+		" ann ,4111111111111111,Ann Lee,x\n" +
+		"bob,12,NULL,y\n"
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader(input), int64(mydump.ReadBlockSize), true, false)
+	require.NoError(t, err)
+
+	split, err := mydump.TransformByName("split", "full", " ", "first", "last")
+	require.NoError(t, err)
+	var out strings.Builder
+	rows, err := mydump.Copy(mydump.NewCSVWriter(&cfg, &out), parser,
+		mydump.TrimColumns(),
+		mydump.UpperColumns("name"),
+		mydump.MaskColumn("card", 4),
+		split,
+		mydump.AddColumn("initial", func(row []mydump.Field) (mydump.Field, error) {
+			return mydump.Field{Val: row[0].Val[:1]}, nil
+		}),
+		mydump.DropColumns("tmp"),
+		mydump.RenameColumn("#0", "who"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rows)
+	require.Equal(t, "who,card,first,last,initial\r\n"+
+		"ANN,************1111,Ann,Lee,A\r\n"+
+		"BOB,12,NULL,NULL,B\r\n", out.String())
+}
+
+func TestTransformReader(t *testing.T) {
+	cfg := mydump.CSVConfig{FieldTerminatedBy: ","}
+	parser, err := mydump.NewCSVParser(&cfg, NewStringReader("a-b,1\nc,2\n"), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+
+	// without a header, the columns are referenced by position.
+	reader := mydump.NewTransformReader(parser,
+		mydump.SplitColumn("#0", "-", "x", "y"),
+		mydump.MapRows("check", func(row []mydump.Field) ([]mydump.Field, error) {
+			if row[2].Val == "2" {
+				return nil, errors.New("no 2")
+			}
+			return row, nil
+		}),
+	)
+	row, err := reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, []mydump.Field{
+		newStringField("a", false),
+		newStringField("b", false),
+		newStringField("1", false),
+	}, row)
+	require.Nil(t, reader.Columns())
+	_, err = reader.Read()
+	require.EqualError(t, err, "transform check: no 2")
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, parser.Pos(), reader.Pos())
+
+	parser, err = mydump.NewCSVParser(&cfg, NewStringReader("a\nb\n"), int64(mydump.ReadBlockSize), false, false)
+	require.NoError(t, err)
+	reader = mydump.NewTransformReader(parser, mydump.TrimColumns(), mydump.UpperColumns("name"))
+	_, err = reader.Read()
+	require.EqualError(t, err, "transform upper: column name is not in the columns []")
+	// the bind error is kept, and src isn't read anymore.
+	pos := reader.Pos()
+	_, err2 := reader.Read()
+	require.Equal(t, err, err2)
+	require.Equal(t, pos, reader.Pos())
+	require.Nil(t, reader.Columns())
+
+	_, _, err = mydump.SplitColumn("#5", "-", "x", "y").Bind([]string{"a", "b"})
+	require.EqualError(t, err, "column #5 is out of the 2 columns")
+	_, _, err = mydump.RenameColumn("#2", "c").Bind([]string{"a", "b"})
+	require.EqualError(t, err, "column #2 is out of the 2 columns")
+
+	for _, args := range [][]string{{"mask", "a"}, {"mask", "a", "b"}, {"split", "a", ","}, {"rename", "a"}, {"unknown"}} {
+		_, err = mydump.TransformByName(args[0], args[1:]...)
+		require.Error(t, err, args)
+	}
+}